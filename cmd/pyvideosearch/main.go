@@ -3,11 +3,15 @@ package main
 import (
 	"context"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/zerok/pyvideosearch/http"
 	"github.com/zerok/pyvideosearch/index"
+	"github.com/zerok/pyvideosearch/source"
+	"github.com/zerok/pyvideosearch/synonyms"
 
 	"runtime"
 
@@ -25,15 +29,46 @@ func main() {
 	var baseURL string
 	var checkInterval time.Duration
 	var startHTTPD bool
+	var maxPageSize int
+	var synonymFile string
+	var boostFile string
+	var recencyHalfLife string
+	var gitOpts source.GitOptions
+	var gitRefMode string
+	var sourceType string
+	var archiveLocation string
+	var reindexSecret string
+	var adminToken string
+	var reindexDebounce time.Duration
+	var watch bool
+	var watchDelay time.Duration
+	var strict bool
 	allowedOrigins := make([]string, 0, 1)
 	pflag.StringVar(&dataFolder, "data-path", "", "Path to the pyvideo data folder")
 	pflag.StringVar(&indexPath, "index-path", "search.bleve", "Path to the search index folder")
 	pflag.StringVar(&addr, "http-addr", "127.0.0.1:8080", "Address the HTTP server should listen on for API calls")
 	pflag.BoolVar(&startHTTPD, "http", false, "Start HTTPD")
 	pflag.BoolVar(&forceRebuild, "force-rebuild", false, "Rebuild the index even if it already exists")
+	pflag.BoolVar(&strict, "strict", true, "Fail if a data file can't be parsed. Otherwise broken files are skipped and reported")
 	pflag.StringVar(&baseURL, "base-url", "http://pyvideo.org", "Base URL of the pyvideo website")
 	pflag.StringSliceVar(&allowedOrigins, "allowed-origin", []string{"http://localhost:8000"}, "(CORS) allowed hostname for XHRs")
-	pflag.DurationVar(&checkInterval, "check-interval", 0, "Interval in which the data folder is updated from upstream using git pull")
+	pflag.IntVar(&maxPageSize, "max-page-size", 100, "Maximum number of search results a client can request per page")
+	pflag.StringVar(&synonymFile, "synonyms", "", "Path to a YAML or JSON file with synonyms used to expand search queries. Reloaded on SIGHUP")
+	pflag.StringVar(&boostFile, "boosts", "", "Path to a YAML or JSON file with the boosts of the title, speakers, collection, tags and description fields")
+	pflag.StringVar(&recencyHalfLife, "recency-half-life", "0", "Age (e.g. 2y or 180d) at which the recency boost of search results has decayed by half. 0 disables the boost")
+	pflag.DurationVar(&checkInterval, "check-interval", 0, "Interval in which the data folder is updated from upstream")
+	pflag.StringVar(&reindexSecret, "reindex-secret", os.Getenv("PYVIDEOSEARCH_REINDEX_SECRET"), "Secret used to verify webhooks sent to /api/v1/admin/reindex. The endpoint is disabled without it")
+	pflag.StringVar(&adminToken, "admin-token", os.Getenv("PYVIDEOSEARCH_ADMIN_TOKEN"), "Bearer token required by /api/v1/admin/index-report. The endpoint is disabled without it")
+	pflag.DurationVar(&reindexDebounce, "reindex-debounce", 10*time.Second, "Time to wait for further webhooks before updating the index")
+	pflag.BoolVar(&watch, "watch", false, "Update the index as soon as files in the data folder are saved. Can't be combined with --check-interval or --reindex-secret")
+	pflag.DurationVar(&watchDelay, "watch-delay", 200*time.Millisecond, "Time to wait for further changes of the data folder before updating the index in watch mode")
+	pflag.StringVar(&sourceType, "source", "git", "Type of the data folder: a git checkout (git), a plain folder (directory) or the extracted --archive (archive)")
+	pflag.StringVar(&archiveLocation, "archive", "", "Path or HTTP URL of a .tar.gz or .zip archive of the data that is extracted into --data-path")
+	pflag.StringVar(&gitOpts.Remote, "git-remote", "origin", "Name of the git remote the data is fetched from")
+	pflag.StringVar(&gitOpts.Branch, "git-branch", "main", "Branch of the git remote the data folder follows")
+	pflag.StringVar(&gitRefMode, "git-ref-mode", "branch", "How the data is updated: follow the branch (branch), check out the newest tag matching --git-tag-pattern (tag) or stay at --git-ref (fixed)")
+	pflag.StringVar(&gitOpts.TagPattern, "git-tag-pattern", "*", "Glob the tags have to match in the tag ref mode (e.g. v*)")
+	pflag.StringVar(&gitOpts.FixedRef, "git-ref", "", "Tag or commit checked out in the fixed ref mode")
 	pflag.Parse()
 
 	logger := zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr})
@@ -52,19 +87,58 @@ func main() {
 		mainGrp.Add(1)
 	}
 
+	var src source.Source
+	switch sourceType {
+	case "git":
+		mode, err := source.ParseRefMode(gitRefMode)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Invalid --git-ref-mode")
+		}
+		gitOpts.Mode = mode
+		if src, err = source.NewGit(dataFolder, gitOpts); err != nil {
+			logger.Fatal().Err(err).Msg("Invalid git options")
+		}
+	case "directory":
+		src = source.NewDirectory(dataFolder)
+	case "archive":
+		if archiveLocation == "" {
+			logger.Fatal().Msg("Please specify the archive to extract using --archive")
+		}
+		src = source.NewArchive(archiveLocation, dataFolder)
+	default:
+		logger.Fatal().Msgf("Unknown --source %s (expected git, directory or archive)", sourceType)
+	}
+
+	if watch && (checkInterval != 0 || reindexSecret != "") {
+		logger.Fatal().Msg("--watch can't be combined with --check-interval or --reindex-secret")
+	}
+
+	var trigger *index.Trigger
+	if startHTTPD && reindexSecret != "" {
+		trigger = index.NewTrigger(reindexDebounce)
+	}
+
 	go func() {
-		idx, err := index.LoadIndex(ctx, indexPath, dataFolder, forceRebuild, true)
+		idx, err := index.LoadIndex(ctx, indexPath, src, forceRebuild, strict, true)
 		if err != nil {
 			logger.Fatal().Err(err).Msgf("Failed to load index on %s", indexPath)
 		}
 		idxChan <- idx
 
-		if checkInterval == 0 {
+		if watch {
+			if err := index.WatchFolder(ctx, idxChan, idx, indexPath, src, watchDelay, strict, !startHTTPD); err != nil {
+				logger.Fatal().Err(err).Msg("Failed to watch data folder")
+			}
+			mainGrp.Done()
+			return
+		}
+
+		if checkInterval == 0 && trigger == nil {
 			logger.Info().Msg("Check interval set to 0. Disabling automatic updates.")
 			return
 		}
 
-		if err := index.WatchForUpdates(ctx, idxChan, indexPath, dataFolder, checkInterval, !startHTTPD); err != nil {
+		if err := index.WatchForUpdates(ctx, idxChan, idx, indexPath, src, checkInterval, trigger, strict, !startHTTPD); err != nil {
 			logger.Fatal().Err(err).Msg("Failed to watch-update data folder")
 		}
 
@@ -72,7 +146,37 @@ func main() {
 	}()
 
 	if startHTTPD {
-		if err := http.RunHTTPD(ctx, idxChan, addr, allowedOrigins); err != nil {
+		opts := http.Options{
+			Addr:           addr,
+			AllowedOrigins: allowedOrigins,
+			MaxPageSize:    maxPageSize,
+			ReindexSecret:  reindexSecret,
+			AdminToken:     adminToken,
+		}
+		if trigger != nil {
+			opts.Reindex = trigger.Request
+		}
+		if synonymFile != "" {
+			syn, err := synonyms.Load(synonymFile)
+			if err != nil {
+				logger.Fatal().Err(err).Msg("Failed to load synonyms")
+			}
+			go reloadOnHangup(ctx, syn)
+			opts.Synonyms = syn
+		}
+		halfLife, err := http.ParseHalfLife(recencyHalfLife)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Invalid --recency-half-life")
+		}
+		opts.RecencyHalfLife = halfLife
+		if boostFile != "" {
+			boosts, err := http.LoadFieldBoosts(boostFile)
+			if err != nil {
+				logger.Fatal().Err(err).Msg("Failed to load boosts")
+			}
+			opts.Boosts = boosts
+		}
+		if err := http.RunHTTPD(ctx, idxChan, opts); err != nil {
 			logger.Fatal().Err(err).Msgf("Failed to start HTTPD on %s", addr)
 		}
 		mainGrp.Done()
@@ -80,3 +184,23 @@ func main() {
 
 	mainGrp.Wait()
 }
+
+// reloadOnHangup reloads the synonyms whenever the process receives SIGHUP.
+func reloadOnHangup(ctx context.Context, syn *synonyms.Synonyms) {
+	logger := zerolog.Ctx(ctx)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			if err := syn.Reload(); err != nil {
+				logger.Error().Err(err).Msg("Failed to reload synonyms")
+				continue
+			}
+			logger.Info().Msg("Synonyms reloaded")
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/zerok/pyvideosearch/index"
+)
+
+// maxWebhookSize is the maximum size of webhook payloads GitHub sends.
+const maxWebhookSize = 25 << 20
+
+type reindexResponse struct {
+	Status string `json:"status"`
+}
+
+// handleReindex accepts GitHub-style push webhooks and requests an update
+// of the index. The payload has to be signed with the reindex secret.
+func (s *server) handleReindex(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookSize))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, "Payload too large")
+		return
+	}
+	if !validSignature(s.opts.ReindexSecret, body, r.Header.Get("X-Hub-Signature-256")) {
+		writeError(w, http.StatusUnauthorized, "Invalid signature")
+		return
+	}
+	switch r.Header.Get("X-GitHub-Event") {
+	case "ping":
+		writeJSON(w, http.StatusOK, reindexResponse{Status: "ok"})
+	case "push", "":
+		s.opts.Reindex()
+		writeJSON(w, http.StatusAccepted, reindexResponse{Status: "scheduled"})
+	default:
+		writeJSON(w, http.StatusOK, reindexResponse{Status: "ignored"})
+	}
+}
+
+type indexReportResponse struct {
+	Problems []index.Problem `json:"problems"`
+}
+
+// handleIndexReport lists the data files that were skipped while indexing
+// because they are broken. The request has to carry the admin token.
+func (s *server) handleIndexReport(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if !validToken(s.opts.AdminToken, r.Header.Get("Authorization")) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+	s.idxLock.RLock()
+	problems := s.idx.Problems()
+	s.idxLock.RUnlock()
+	writeJSON(w, http.StatusOK, indexReportResponse{Problems: problems})
+}
+
+// validToken checks an authorization header like Bearer <token> against
+// token.
+func validToken(token string, header string) bool {
+	given, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+// validSignature checks a signature header like sha256=<hex> against the
+// HMAC-SHA256 of body.
+func validSignature(secret string, body []byte, header string) bool {
+	hexSignature, ok := strings.CutPrefix(header, "sha256=")
+	if !ok {
+		return false
+	}
+	signature, err := hex.DecodeString(hexSignature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(signature, mac.Sum(nil))
+}
@@ -0,0 +1,117 @@
+package http
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zerok/pyvideosearch/index"
+	"github.com/zerok/pyvideosearch/source"
+)
+
+func TestReindexWebhook(t *testing.T) {
+	requests := 0
+	srv := newTestServer(t)
+	srv.opts.ReindexSecret = "secret"
+	srv.opts.Reindex = func() { requests++ }
+
+	send := func(event string, body string, signature string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/reindex", strings.NewReader(body))
+		req.Header.Set("X-GitHub-Event", event)
+		if signature != "" {
+			req.Header.Set("X-Hub-Signature-256", signature)
+		}
+		srv.router().ServeHTTP(rec, req)
+		return rec
+	}
+	sign := func(secret string, body string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(body))
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	body := `{"ref": "refs/heads/main"}`
+	rec := send("push", body, sign("secret", body))
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	require.Equal(t, "scheduled", decodeBody(t, rec)["status"])
+	require.Equal(t, 1, requests)
+
+	rec = send("ping", body, sign("secret", body))
+	require.Equal(t, http.StatusOK, rec.Code)
+	rec = send("issues", body, sign("secret", body))
+	require.Equal(t, "ignored", decodeBody(t, rec)["status"])
+	require.Equal(t, 1, requests)
+
+	for _, signature := range []string{"", sign("other", body), "sha256=zz", sign("secret", body)[7:]} {
+		rec = send("push", body, signature)
+		require.Equal(t, http.StatusUnauthorized, rec.Code, signature)
+	}
+	rec = send("push", body+" ", sign("secret", body))
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.Equal(t, 1, requests)
+}
+
+func TestReindexWebhookDisabled(t *testing.T) {
+	srv := newTestServer(t)
+	srv.opts.Reindex = func() {}
+	rec := httptest.NewRecorder()
+	srv.router().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/admin/reindex", nil))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestIndexReport(t *testing.T) {
+	srv := newTestServer(t)
+	srv.opts.AdminToken = "token"
+	report := func(authorization string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/index-report", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		srv.router().ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, authorization := range []string{"", "Bearer other", "Bearer ", "token", "Basic token"} {
+		rec := report(authorization)
+		require.Equal(t, http.StatusUnauthorized, rec.Code, authorization)
+	}
+
+	rec := report("Bearer token")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, []interface{}{}, decodeBody(t, rec)["problems"])
+
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "conf", "videos"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "conf", "category.json"), []byte(`{"title": "Conf"}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "conf", "videos", "broken.json"), []byte("{\n  \"title\"\n}"), 0644))
+	idx, err := index.LoadIndex(context.Background(), t.TempDir(), source.NewDirectory(root), false, false, true)
+	require.NoError(t, err)
+	srv.swapIndex(idx)
+
+	rec = report("Bearer token")
+	require.Equal(t, http.StatusOK, rec.Code)
+	problems := decodeBody(t, rec)["problems"].([]interface{})
+	require.Len(t, problems, 1)
+	problem := problems[0].(map[string]interface{})
+	require.Equal(t, "conf/videos/broken.json", problem["file"])
+	require.Equal(t, 3.0, problem["line"])
+	require.Equal(t, 1.0, problem["column"])
+	require.NotEmpty(t, problem["error"])
+}
+
+func TestIndexReportDisabled(t *testing.T) {
+	srv := newTestServer(t)
+	rec := httptest.NewRecorder()
+	srv.router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/index-report", nil))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,72 @@
+package http
+
+import (
+	"bytes"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// FieldBoosts weights matches of a plain query in the individual fields of
+// a session. A boost of 0 disables the field, but the query is still
+// matched against all fields with the default weight.
+type FieldBoosts struct {
+	Title       float64 `yaml:"title"`
+	Speakers    float64 `yaml:"speakers"`
+	Collection  float64 `yaml:"collection"`
+	Tags        float64 `yaml:"tags"`
+	Description float64 `yaml:"description"`
+}
+
+// DefaultFieldBoosts ranks a match in the title above all others while a
+// match in the description counts the least.
+func DefaultFieldBoosts() FieldBoosts {
+	return FieldBoosts{
+		Title:       3,
+		Speakers:    2,
+		Collection:  1.5,
+		Tags:        2,
+		Description: 1,
+	}
+}
+
+// LoadFieldBoosts reads the boosts from a YAML or JSON file. Fields missing
+// in the file keep their default boost. Unknown fields are rejected so that
+// typos don't go unnoticed.
+func LoadFieldBoosts(path string) (*FieldBoosts, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read boost file %s", path)
+	}
+	boosts := DefaultFieldBoosts()
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&boosts); err != nil && err != io.EOF {
+		return nil, errors.Wrapf(err, "Failed to parse boost file %s", path)
+	}
+	for _, f := range boosts.fields() {
+		if f.boost < 0 {
+			return nil, errors.Errorf("Boost of %s in %s must not be negative", f.name, path)
+		}
+	}
+	return &boosts, nil
+}
+
+type fieldBoost struct {
+	name  string
+	field string
+	boost float64
+}
+
+// fields returns the boosts together with the indexed fields they apply to.
+func (b FieldBoosts) fields() []fieldBoost {
+	return []fieldBoost{
+		{"title", "title", b.Title},
+		{"speakers", "speakers.name_text", b.Speakers},
+		{"collection", "collection_title_text", b.Collection},
+		{"tags", "tags_text", b.Tags},
+		{"description", "description", b.Description},
+	}
+}
@@ -0,0 +1,37 @@
+package http
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadFieldBoosts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "boosts.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("title: 5\ndescription: 0.5\n"), 0600))
+	boosts, err := LoadFieldBoosts(path)
+	require.NoError(t, err)
+	expected := DefaultFieldBoosts()
+	expected.Title = 5
+	expected.Description = 0.5
+	require.Equal(t, expected, *boosts)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"tags": -1}`), 0600))
+	_, err = LoadFieldBoosts(path)
+	require.Error(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte("titel: 5\n"), 0600))
+	_, err = LoadFieldBoosts(path)
+	require.ErrorContains(t, err, path)
+	require.ErrorContains(t, err, "titel")
+
+	require.NoError(t, os.WriteFile(path, nil, 0600))
+	boosts, err = LoadFieldBoosts(path)
+	require.NoError(t, err)
+	require.Equal(t, DefaultFieldBoosts(), *boosts)
+
+	_, err = LoadFieldBoosts(filepath.Join(t.TempDir(), "missing.yaml"))
+	require.Error(t, err)
+}
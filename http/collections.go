@@ -0,0 +1,131 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search"
+	"github.com/julienschmidt/httprouter"
+	"github.com/zerok/pyvideosearch/slugify"
+)
+
+type collectionEntry struct {
+	collectionRef
+	SessionCount int    `json:"session_count"`
+	RecordedFrom string `json:"recorded_from,omitempty"`
+	RecordedTo   string `json:"recorded_to,omitempty"`
+}
+
+type collectionListResponse struct {
+	Collections []collectionEntry `json:"collections"`
+	Paging      paging            `json:"paging"`
+}
+
+type collectionResponse struct {
+	collectionEntry
+	Sessions []sessionSummary `json:"sessions"`
+}
+
+// handleCollections lists all collections ordered by their slug. Just like
+// the speaker listing it supports filtering by a slug prefix.
+func (s *server) handleCollections(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	page, size, err := parsePageAndSize(r, s.opts.MaxPageSize)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	prefix := slugify.Slugify(r.FormValue("prefix"))
+
+	s.idxLock.RLock()
+	defer s.idxLock.RUnlock()
+	terms, err := fieldTerms(s.idx.Index, "collection_slug", prefix)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to list collections")
+		return
+	}
+	resp := collectionListResponse{
+		Collections: make([]collectionEntry, 0, size),
+		Paging:      newListPaging(r, page, size, len(terms)),
+	}
+	for _, term := range pageOf(terms, page, size) {
+		entry, err := loadCollectionEntry(s.idx.Index, term.Term)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to list collections")
+			return
+		}
+		entry.SessionCount = int(term.Count)
+		resp.Collections = append(resp.Collections, entry)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *server) handleCollection(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	slug := ps.ByName("slug")
+	q := bleve.NewTermQuery(slug)
+	q.SetField("collection_slug")
+
+	s.idxLock.RLock()
+	defer s.idxLock.RUnlock()
+	sessions, err := searchSessions(s.idx.Index, q)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load collection")
+		return
+	}
+	if len(sessions) == 0 {
+		writeError(w, http.StatusNotFound, "Collection not found")
+		return
+	}
+	resp := collectionResponse{
+		collectionEntry: collectionEntry{
+			collectionRef: collectionRef{
+				Title: sessions[0].CollectionTitle,
+				Slug:  slug,
+				URL:   sessions[0].CollectionURL,
+			},
+			SessionCount: len(sessions),
+		},
+		Sessions: sessions,
+	}
+	// Sessions are ordered by date with those lacking one at the end.
+	for _, session := range sessions {
+		if session.Recorded == "" {
+			continue
+		}
+		if resp.RecordedFrom == "" {
+			resp.RecordedFrom = session.Recorded
+		}
+		resp.RecordedTo = session.Recorded
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// loadCollectionEntry fetches the title and the date range of a collection
+// by looking at its oldest and newest session.
+func loadCollectionEntry(idx bleve.Index, slug string) (collectionEntry, error) {
+	entry := collectionEntry{}
+	entry.Slug = slug
+	for _, desc := range []bool{false, true} {
+		q := bleve.NewTermQuery(slug)
+		q.SetField("collection_slug")
+		req := bleve.NewSearchRequest(q)
+		req.Size = 1
+		req.Fields = []string{"collection_title", "collection_url", "recorded"}
+		req.SortByCustom(search.SortOrder{recordedSort(desc), &search.SortDocID{}})
+		res, err := idx.Search(req)
+		if err != nil {
+			return entry, err
+		}
+		if len(res.Hits) == 0 {
+			return entry, nil
+		}
+		fields := res.Hits[0].Fields
+		entry.Title = fieldString(fields, "collection_title")
+		entry.URL = fieldString(fields, "collection_url")
+		if desc {
+			entry.RecordedTo = fieldString(fields, "recorded")
+		} else {
+			entry.RecordedFrom = fieldString(fields, "recorded")
+		}
+	}
+	return entry, nil
+}
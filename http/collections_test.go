@@ -0,0 +1,40 @@
+package http
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollections(t *testing.T) {
+	srv := newTestServer(t)
+
+	body := doRequest(t, srv, "/api/v1/collections")
+	collections := body["collections"].([]interface{})
+	require.Len(t, collections, 2)
+	require.Equal(t, map[string]interface{}{
+		"title":         "PyCon 2018",
+		"slug":          "pycon-2018",
+		"url":           "",
+		"session_count": float64(2),
+		"recorded_from": "2018-05-11T00:00:00Z",
+		"recorded_to":   "2018-05-11T00:00:00Z",
+	}, collections[1])
+
+	body = doRequest(t, srv, "/api/v1/collections?prefix=pycon-2016")
+	require.Len(t, body["collections"], 1)
+}
+
+func TestCollection(t *testing.T) {
+	srv := newTestServer(t)
+
+	body := doRequest(t, srv, "/api/v1/collections/pycon-2018")
+	require.Equal(t, "PyCon 2018", body["title"])
+	require.Equal(t, float64(2), body["session_count"])
+	require.Equal(t, "2018-05-11T00:00:00Z", body["recorded_from"])
+	require.Len(t, body["sessions"], 2)
+
+	rec := serve(srv, "/api/v1/collections/unknown")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
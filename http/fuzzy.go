@@ -0,0 +1,134 @@
+package http
+
+import (
+	"strings"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// didYouMeanField is the field whose terms are used to correct queries.
+const didYouMeanField = "title"
+
+// fuzziness returns the edit distance allowed for a term. Short terms only
+// allow a single edit as most other terms would match otherwise.
+func fuzziness(term string) int {
+	switch n := len([]rune(term)); {
+	case n < 3:
+		return 0
+	case n < 6:
+		return 1
+	}
+	return 2
+}
+
+// analyzeQuery returns the terms of input as they are indexed in the title.
+// Stop words are removed.
+func analyzeQuery(idx bleve.Index, input string) []string {
+	m := idx.Mapping()
+	analyzer := m.AnalyzerNamed(m.AnalyzerNameForPath(didYouMeanField))
+	if analyzer == nil {
+		return strings.Fields(strings.ToLower(input))
+	}
+	tokens := analyzer.Analyze([]byte(input))
+	terms := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		terms = append(terms, string(token.Term))
+	}
+	return terms
+}
+
+// fuzzyTextQuery matches any of the terms with the edit distance allowed
+// for its length.
+func fuzzyTextQuery(terms []string) query.Query {
+	disjuncts := make([]query.Query, 0, len(terms))
+	for _, term := range terms {
+		q := bleve.NewFuzzyQuery(term)
+		q.SetFuzziness(fuzziness(term))
+		disjuncts = append(disjuncts, q)
+	}
+	return bleve.NewDisjunctionQuery(disjuncts...)
+}
+
+// didYouMean replaces every term of input that doesn't appear in any title
+// with the most frequent title term within its edit distance. It returns
+// an empty string if nothing could be corrected.
+func didYouMean(idx bleve.Index, input string, terms []string) (string, error) {
+	corrections := make(map[string]*correction, len(terms))
+	for _, term := range terms {
+		if fuzziness(term) > 0 {
+			corrections[term] = &correction{}
+		}
+	}
+	if len(corrections) == 0 {
+		return "", nil
+	}
+	entries, err := fieldTerms(idx, didYouMeanField, "")
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range entries {
+		for term, c := range corrections {
+			maxDistance := fuzziness(term)
+			distance := editDistance(term, entry.Term, maxDistance+1)
+			if distance > maxDistance {
+				continue
+			}
+			if c.term == "" || distance < c.distance || (distance == c.distance && entry.Count > c.count) {
+				c.term, c.distance, c.count = entry.Term, distance, entry.Count
+			}
+		}
+	}
+
+	changed := false
+	words := strings.Fields(strings.ToLower(input))
+	for i, word := range words {
+		c, ok := corrections[word]
+		if !ok || c.term == "" || c.term == word {
+			continue
+		}
+		words[i] = c.term
+		changed = true
+	}
+	if !changed {
+		return "", nil
+	}
+	return strings.Join(words, " "), nil
+}
+
+type correction struct {
+	term     string
+	distance int
+	count    uint64
+}
+
+// editDistance calculates the Levenshtein distance between a and b. It
+// gives up as soon as the distance reaches limit and returns limit then.
+func editDistance(a string, b string, limit int) int {
+	ra, rb := []rune(a), []rune(b)
+	if d := len(ra) - len(rb); d >= limit || -d >= limit {
+		return limit
+	}
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			rowMin = min(rowMin, cur[j])
+		}
+		if rowMin >= limit {
+			return limit
+		}
+		prev, cur = cur, prev
+	}
+	return min(prev[len(rb)], limit)
+}
@@ -0,0 +1,27 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEditDistance(t *testing.T) {
+	testcases := []struct {
+		a, b     string
+		limit    int
+		expected int
+	}{
+		{a: "asyncio", b: "asyncio", limit: 3, expected: 0},
+		{a: "asyncoi", b: "asyncio", limit: 3, expected: 2},
+		{a: "pakaging", b: "packaging", limit: 3, expected: 1},
+		{a: "django", b: "flask", limit: 3, expected: 3},
+		{a: "py", b: "python", limit: 3, expected: 3},
+		{a: "ñandu", b: "nandu", limit: 2, expected: 1},
+	}
+	for _, testcase := range testcases {
+		t.Run(testcase.a+"/"+testcase.b, func(t *testing.T) {
+			require.Equal(t, testcase.expected, editDistance(testcase.a, testcase.b, testcase.limit))
+		})
+	}
+}
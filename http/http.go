@@ -12,26 +12,110 @@ import (
 	"expvar"
 
 	"sync"
+	"time"
 
 	"github.com/blevesearch/bleve/v2"
 	"github.com/julienschmidt/httprouter"
 	"github.com/zerok/pyvideosearch/index"
+	"github.com/zerok/pyvideosearch/synonyms"
 )
 
 var searchQueries = expvar.NewInt("pyvideo.search_count")
 
-// RunHTTPD starts the API server on the given addr serving the index.
-// If you need to support XHRs, make sure to pass respective allowedOrigin
-// hosts like http://domain.com:5000.
-func RunHTTPD(ctx context.Context, idxChan chan *index.Index, addr string, allowedOrigins []string) error {
-	logger := zerolog.Ctx(ctx)
+// Options configures the API server started by RunHTTPD.
+type Options struct {
+	// Addr is the address the server listens on.
+	Addr string
+	// AllowedOrigins lists hosts like http://domain.com:5000 that are
+	// allowed to access the API using XHRs.
+	AllowedOrigins []string
+	// MaxPageSize limits the number of hits a client can request per page.
+	MaxPageSize int
+	// Synonyms are used to expand the free-text queries of searches. They
+	// are optional.
+	Synonyms *synonyms.Synonyms
+	// Boosts weights matches in the individual fields of a session.
+	// DefaultFieldBoosts are used if it isn't set.
+	Boosts *FieldBoosts
+	// RecencyHalfLife is the age at which the recency boost has decayed
+	// by half. 0 disables the boost unless a search requests it.
+	RecencyHalfLife time.Duration
+	// ReindexSecret is used to verify the signature of webhooks sent to
+	// the reindex endpoint. The endpoint is disabled if it isn't set.
+	ReindexSecret string
+	// Reindex is called to request an update of the index whenever a
+	// webhook reports a push.
+	Reindex func()
+	// AdminToken has to be passed as bearer token to the index report
+	// endpoint. The endpoint is disabled if it isn't set.
+	AdminToken string
+}
+
+type server struct {
+	idxLock sync.RWMutex
+	idx     *index.Index
+	opts    Options
+}
+
+func newServer(idx *index.Index, opts Options) *server {
+	if opts.MaxPageSize <= 0 {
+		opts.MaxPageSize = defaultPageSize
+	}
+	if opts.Boosts == nil {
+		boosts := DefaultFieldBoosts()
+		opts.Boosts = &boosts
+	}
+	return &server{
+		idx:  idx,
+		opts: opts,
+	}
+}
+
+// swapIndex replaces the index served by s and releases the previous one.
+// An index that was updated in place is sent again and is kept.
+func (s *server) swapIndex(i *index.Index) {
+	s.idxLock.Lock()
+	defer s.idxLock.Unlock()
+	if i == s.idx {
+		return
+	}
+	s.idx.Close()
+	s.idx.Destroy()
+	s.idx = i
+}
+
+func (s *server) router() http.Handler {
 	router := httprouter.New()
+	router.Handler(http.MethodGet, "/api/v1/metrics", expvar.Handler())
+	router.GET("/api/v1/search", s.handleSearch)
+	router.GET("/api/v2/search", s.handleSearchV2)
+	router.GET("/api/v1/suggest", s.handleSuggest)
+	router.GET("/api/v1/speakers", s.handleSpeakers)
+	router.GET("/api/v1/speakers/:slug", s.handleSpeaker)
+	router.GET("/api/v1/collections", s.handleCollections)
+	router.GET("/api/v1/collections/:slug", s.handleCollection)
+	router.GET("/api/v1/sessions", s.handleSessionByURL)
+	router.GET("/api/v1/sessions/:collection/:slug", s.handleSession)
+	router.GET("/api/v1/sessions/:collection/:slug/related", s.handleRelated)
+	if s.opts.AdminToken != "" {
+		router.GET("/api/v1/admin/index-report", s.handleIndexReport)
+	}
+	if s.opts.ReindexSecret != "" && s.opts.Reindex != nil {
+		router.POST("/api/v1/admin/reindex", s.handleReindex)
+	}
+	return router
+}
+
+// RunHTTPD starts the API server on the configured address serving the
+// index. If you need to support XHRs, make sure to pass respective
+// AllowedOrigins in opts.
+func RunHTTPD(ctx context.Context, idxChan chan *index.Index, opts Options) error {
+	logger := zerolog.Ctx(ctx)
 
-	idxLock := sync.RWMutex{}
-	i, _ := bleve.NewMemOnly(bleve.NewIndexMapping())
-	idx := &index.Index{
+	i, _ := bleve.NewMemOnly(index.NewMapping())
+	srv := newServer(&index.Index{
 		Index: i,
-	}
+	}, opts)
 
 	go func() {
 		for {
@@ -39,46 +123,31 @@ func RunHTTPD(ctx context.Context, idxChan chan *index.Index, addr string, allow
 			case <-ctx.Done():
 				return
 			case i := <-idxChan:
-				idxLock.Lock()
-				idx.Close()
-				idx.Destroy()
-				idx = i
-				idxLock.Unlock()
+				srv.swapIndex(i)
 				logger.Info().Msg("Index updated for HTTPD")
 			}
 		}
 	}()
 
-	router.Handler(http.MethodGet, "/api/v1/metrics", expvar.Handler())
-
-	router.GET("/api/v1/search", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-		searchQueries.Add(1)
-		qs := r.FormValue("q")
-		q := bleve.NewQueryStringQuery(qs)
-		req := bleve.NewSearchRequest(q)
-		req.Fields = []string{"title", "url", "conference", "speakers.name", "speakers.slug", "thumbnail_url", "collection_title", "collection_url", "recorded", "recorded_formatted"}
-		req.Size = 100
-		req.IncludeLocations = true
-		collectionFacet := bleve.NewFacetRequest("collection_title", 10)
-		speakerFacet := bleve.NewFacetRequest("speakers", 10)
-		req.AddFacet("speaker", speakerFacet)
-		req.AddFacet("collection", collectionFacet)
-		idxLock.RLock()
-		defer idxLock.RUnlock()
-		res, err := idx.Index.Search(req)
-		if err != nil {
-			http.Error(w, "Query failed", http.StatusInternalServerError)
-			return
-		}
-		w.Header().Set("Content-type", "application/json")
-		json.NewEncoder(w).Encode(res)
-	})
-
 	c := cors.New(cors.Options{
-		AllowedOrigins:   allowedOrigins,
+		AllowedOrigins:   opts.AllowedOrigins,
 		AllowCredentials: true,
 	})
 
-	logger.Info().Msgf("Starting server on %s (allowing XHR from %s)", addr, allowedOrigins)
-	return http.ListenAndServe(addr, c.Handler(router))
+	logger.Info().Msgf("Starting server on %s (allowing XHR from %s)", opts.Addr, opts.AllowedOrigins)
+	return http.ListenAndServe(opts.Addr, c.Handler(srv.router()))
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, errorResponse{Error: msg})
 }
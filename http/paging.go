@@ -0,0 +1,265 @@
+package http
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search"
+)
+
+const defaultPageSize = 100
+
+// maxOffset limits how deep page-based access can go. Larger offsets would
+// overflow once bleve adds the page size. Deeper hits are still reachable
+// using cursors.
+const maxOffset = math.MaxInt32
+
+// cursor marks a position within a sorted result set. Values are the sort
+// values of the hit the cursor points at, which keeps the position stable
+// even if documents are added or removed in between two requests. Offset
+// is only informational and is used to render the paging metadata.
+//
+// Sort values can be prefix-coded binary data, so they are stored as bytes
+// to survive the JSON round-trip.
+type cursor struct {
+	Sort   string   `json:"s"`
+	Values [][]byte `json:"v"`
+	Offset int      `json:"o"`
+}
+
+func (c *cursor) sortValues() []string {
+	values := make([]string, 0, len(c.Values))
+	for _, v := range c.Values {
+		values = append(values, string(v))
+	}
+	return values
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	c := cursor{}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	if len(c.Values) == 0 || c.Offset < 0 {
+		return nil, fmt.Errorf("empty cursor")
+	}
+	return &c, nil
+}
+
+// pagingParams describes which slice of the result set a client requested.
+// Only one of Page, After and Before is used at a time.
+type pagingParams struct {
+	Sort   string
+	Page   int
+	Size   int
+	After  *cursor
+	Before *cursor
+}
+
+// parsePageAndSize reads the 1-based page and the page size from r.
+func parsePageAndSize(r *http.Request, maxSize int) (int, int, error) {
+	page := 1
+	size := defaultPageSize
+	if size > maxSize {
+		size = maxSize
+	}
+	var err error
+	if v := r.FormValue("size"); v != "" {
+		size, err = strconv.Atoi(v)
+		if err != nil || size < 1 {
+			return 0, 0, fmt.Errorf("Invalid size: %s", v)
+		}
+		if size > maxSize {
+			return 0, 0, fmt.Errorf("size must not be larger than %d", maxSize)
+		}
+	}
+	if v := r.FormValue("page"); v != "" {
+		page, err = strconv.Atoi(v)
+		if err != nil || page < 1 {
+			return 0, 0, fmt.Errorf("Invalid page: %s", v)
+		}
+		if page-1 > maxOffset/size {
+			return 0, 0, fmt.Errorf("page must not be larger than %d", maxOffset/size+1)
+		}
+	}
+	return page, size, nil
+}
+
+func parsePagingParams(r *http.Request, maxSize int, sort string) (*pagingParams, error) {
+	page, size, err := parsePageAndSize(r, maxSize)
+	if err != nil {
+		return nil, err
+	}
+	p := &pagingParams{
+		Sort: sort,
+		Page: page,
+		Size: size,
+	}
+	if v := r.FormValue("search_after"); v != "" {
+		if p.After, err = decodeCursor(v); err != nil {
+			return nil, fmt.Errorf("Invalid search_after cursor")
+		}
+	}
+	if v := r.FormValue("search_before"); v != "" {
+		if p.Before, err = decodeCursor(v); err != nil {
+			return nil, fmt.Errorf("Invalid search_before cursor")
+		}
+	}
+	cursors := 0
+	if p.After != nil {
+		cursors++
+	}
+	if p.Before != nil {
+		cursors++
+	}
+	if r.FormValue("page") != "" {
+		cursors++
+	}
+	if cursors > 1 {
+		return nil, fmt.Errorf("page, search_after and search_before cannot be combined")
+	}
+	for _, c := range []*cursor{p.After, p.Before} {
+		if c != nil && c.Sort != sort {
+			return nil, fmt.Errorf("Cursor was created for a different sort order")
+		}
+	}
+	return p, nil
+}
+
+// apply configures the window of req. req.Sort has to be set before.
+func (p *pagingParams) apply(req *bleve.SearchRequest) {
+	req.Size = p.Size
+	switch {
+	case p.After != nil:
+		req.SearchAfter = p.After.sortValues()
+	case p.Before != nil:
+		req.SearchBefore = p.Before.sortValues()
+	default:
+		req.From = (p.Page - 1) * p.Size
+	}
+}
+
+// offset returns the position of the first hit in res within the whole
+// result set.
+func (p *pagingParams) offset(res *bleve.SearchResult) int {
+	switch {
+	case p.After != nil:
+		return p.After.Offset + 1
+	case p.Before != nil:
+		offset := p.Before.Offset - len(res.Hits)
+		if offset < 0 {
+			offset = 0
+		}
+		return offset
+	default:
+		return (p.Page - 1) * p.Size
+	}
+}
+
+type paging struct {
+	Page       int    `json:"page"`
+	Size       int    `json:"size"`
+	From       int    `json:"from"`
+	TotalHits  uint64 `json:"total_hits"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+	Next       string `json:"next,omitempty"`
+	Prev       string `json:"prev,omitempty"`
+}
+
+// newPaging generates the paging metadata for res. Links to the next and
+// previous page are always cursor-based so that walking through the
+// result set stays consistent while the index gets swapped.
+func newPaging(r *http.Request, p *pagingParams, req *bleve.SearchRequest, res *bleve.SearchResult) paging {
+	from := p.offset(res)
+	pg := paging{
+		Page:      from/p.Size + 1,
+		Size:      p.Size,
+		From:      from,
+		TotalHits: res.Total,
+	}
+	if len(res.Hits) == 0 {
+		return pg
+	}
+	if uint64(from+len(res.Hits)) < res.Total {
+		last := len(res.Hits) - 1
+		pg.NextCursor = encodeCursor(cursor{
+			Sort:   p.Sort,
+			Values: sortValues(req.Sort, res.Hits[last]),
+			Offset: from + last,
+		})
+		pg.Next = pageLink(r, "search_after", pg.NextCursor)
+	}
+	if from > 0 {
+		pg.PrevCursor = encodeCursor(cursor{
+			Sort:   p.Sort,
+			Values: sortValues(req.Sort, res.Hits[0]),
+			Offset: from,
+		})
+		pg.Prev = pageLink(r, "search_before", pg.PrevCursor)
+	}
+	return pg
+}
+
+// newListPaging generates the paging metadata for listings that only
+// support page-based access.
+func newListPaging(r *http.Request, page int, size int, total int) paging {
+	pg := paging{
+		Page:      page,
+		Size:      size,
+		From:      (page - 1) * size,
+		TotalHits: uint64(total),
+	}
+	if pg.From+size < total {
+		pg.Next = pageLink(r, "page", strconv.Itoa(page+1))
+	}
+	if page > 1 {
+		pg.Prev = pageLink(r, "page", strconv.Itoa(page-1))
+	}
+	return pg
+}
+
+// sortValues extracts the values of hit in a form that bleve accepts for
+// SearchAfter and SearchBefore.
+func sortValues(order search.SortOrder, hit *search.DocumentMatch) [][]byte {
+	values := make([][]byte, 0, len(order))
+	for i, s := range order {
+		switch {
+		case s.RequiresScoring():
+			values = append(values, []byte(strconv.FormatFloat(hit.Score, 'g', -1, 64)))
+		case i < len(hit.DecodedSort):
+			values = append(values, []byte(hit.DecodedSort[i]))
+		default:
+			values = append(values, []byte(hit.Sort[i]))
+		}
+	}
+	return values
+}
+
+func pageLink(r *http.Request, param string, value string) string {
+	q := url.Values{}
+	for k, v := range r.URL.Query() {
+		q[k] = v
+	}
+	q.Del("page")
+	q.Del("search_after")
+	q.Del("search_before")
+	q.Set(param, value)
+	u := url.URL{Path: r.URL.Path, RawQuery: q.Encode()}
+	return u.String()
+}
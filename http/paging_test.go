@@ -0,0 +1,93 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchPaging(t *testing.T) {
+	for _, sort := range []string{"relevance", "newest", "oldest", "title"} {
+		t.Run(sort, func(t *testing.T) {
+			srv := newTestServer(t)
+
+			// Collect all hits with a single request as reference:
+			_, all := doSearch(t, srv, "q=asyncio&sort="+sort)
+			expected := make([]string, 0, 3)
+			for i := range all["hits"].([]interface{}) {
+				expected = append(expected, hitID(all, i))
+			}
+			require.Len(t, expected, 3)
+
+			// Now walk through the results one by one using the next links:
+			status, page := doSearch(t, srv, "q=asyncio&size=1&sort="+sort)
+			require.Equal(t, http.StatusOK, status)
+			require.Empty(t, pagingOf(page)["prev"])
+			pages := []map[string]interface{}{page}
+			for pagingOf(page)["next"] != nil {
+				page = doRequest(t, srv, pagingOf(page)["next"].(string))
+				pages = append(pages, page)
+			}
+			require.Len(t, pages, len(expected))
+			for i, page := range pages {
+				require.Equal(t, expected[i], hitID(page, 0))
+				require.Equal(t, float64(i), pagingOf(page)["from"])
+				require.Equal(t, float64(3), pagingOf(page)["total_hits"])
+			}
+
+			// Going back has to yield the previous pages again:
+			prev := doRequest(t, srv, pagingOf(pages[2])["prev"].(string))
+			require.Equal(t, expected[1], hitID(prev, 0))
+			require.Equal(t, float64(1), pagingOf(prev)["from"])
+
+			// Page-based access should return the same hit as the cursor:
+			_, page2 := doSearch(t, srv, "q=asyncio&size=1&page=2&sort="+sort)
+			require.Equal(t, expected[1], hitID(page2, 0))
+		})
+	}
+}
+
+func TestSearchPagingInvalidParameters(t *testing.T) {
+	srv := newTestServer(t)
+	_, first := doSearch(t, srv, "q=asyncio&size=1")
+	next := pagingOf(first)["next_cursor"].(string)
+	for _, query := range []string{
+		"q=asyncio&size=0",
+		"q=asyncio&size=1000",
+		"q=asyncio&page=-1",
+		"q=asyncio&page=922337203685477581&size=10",
+		"q=asyncio&page=4611686018427387904&size=4",
+		"q=asyncio&page=214748366&size=10",
+		"q=asyncio&search_after=garbage",
+		"q=asyncio&page=2&search_after=" + next,
+		"q=asyncio&sort=newest&search_after=" + next,
+	} {
+		t.Run(query, func(t *testing.T) {
+			status, body := doSearch(t, srv, query)
+			require.Equal(t, http.StatusBadRequest, status)
+			require.NotEmpty(t, body["error"])
+		})
+	}
+}
+
+func doRequest(t *testing.T, srv *server, path string) map[string]interface{} {
+	rec := serve(srv, path)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	return decodeBody(t, rec)
+}
+
+func serve(srv *server, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	srv.router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
+
+func hitID(body map[string]interface{}, pos int) string {
+	return body["hits"].([]interface{})[pos].(map[string]interface{})["id"].(string)
+}
+
+func pagingOf(body map[string]interface{}) map[string]interface{} {
+	return body["paging"].(map[string]interface{})
+}
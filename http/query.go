@@ -0,0 +1,70 @@
+package http
+
+import (
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+	"github.com/zerok/pyvideosearch/index"
+	"github.com/zerok/pyvideosearch/synonyms"
+)
+
+// queryStringSyntax are the characters with a special meaning in bleve's
+// query string syntax.
+const queryStringSyntax = `:"*?~^()\/{}[]`
+
+// isPlainQuery checks if input is just a list of words without any of the
+// query string syntax. Only these queries are rewritten as the intent of
+// the other ones can't be preserved.
+func isPlainQuery(input string) bool {
+	if strings.ContainsAny(input, queryStringSyntax) {
+		return false
+	}
+	for _, word := range strings.Fields(input) {
+		if strings.HasPrefix(word, "+") || strings.HasPrefix(word, "-") {
+			return false
+		}
+	}
+	return true
+}
+
+// queryConfig holds the server-wide settings used to build search queries.
+type queryConfig struct {
+	synonyms        *synonyms.Synonyms
+	boosts          FieldBoosts
+	recencyHalfLife time.Duration
+}
+
+func (s *server) queryConfig() queryConfig {
+	return queryConfig{
+		synonyms:        s.opts.Synonyms,
+		boosts:          *s.opts.Boosts,
+		recencyHalfLife: s.opts.RecencyHalfLife,
+	}
+}
+
+// textQuery builds the query for the free-text input of a search. Plain
+// queries additionally match the individual fields weighted by their boost.
+// This includes the language-specific fields so that stemming and stop
+// words of the session's language apply. Synonyms of the terms they contain
+// match as well.
+func textQuery(input string, cfg queryConfig) query.Query {
+	qs := bleve.NewQueryStringQuery(input)
+	if !isPlainQuery(input) {
+		return qs
+	}
+	disjuncts := []query.Query{qs}
+	for _, f := range cfg.boosts.fields() {
+		if f.boost == 0 {
+			continue
+		}
+		for _, field := range append([]string{f.field}, index.LanguageVariants(f.field)...) {
+			disjuncts = append(disjuncts, boostedMatch(input, field, f.boost))
+		}
+	}
+	for _, synonym := range cfg.synonyms.Expand(input) {
+		disjuncts = append(disjuncts, bleve.NewMatchPhraseQuery(synonym))
+	}
+	return bleve.NewDisjunctionQuery(disjuncts...)
+}
@@ -0,0 +1,88 @@
+package http
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve/v2/search"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// recencyFloor is the share of the relevance score a session keeps no
+// matter how old it is. This keeps classics from being buried completely.
+const recencyFloor = 0.5
+
+// ParseHalfLife parses the half-life of the recency boost. Besides Go
+// durations like 8760h it accepts days (180d) and years (2y). 0 disables
+// the boost.
+func ParseHalfLife(v string) (time.Duration, error) {
+	var unit time.Duration
+	switch {
+	case strings.HasSuffix(v, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(v, "y"):
+		unit = time.Duration(365.25 * 24 * float64(time.Hour))
+	}
+	var d time.Duration
+	if unit == 0 {
+		var err error
+		if d, err = time.ParseDuration(v); err != nil {
+			return 0, fmt.Errorf("Invalid half-life: %s", v)
+		}
+	} else {
+		n, err := strconv.ParseFloat(v[:len(v)-1], 64)
+		if err != nil || math.IsInf(n, 0) || math.IsNaN(n) {
+			return 0, fmt.Errorf("Invalid half-life: %s", v)
+		}
+		d = time.Duration(n * float64(unit))
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("Invalid half-life: %s", v)
+	}
+	return d, nil
+}
+
+// recencyFactor blends an exponential decay by age into the score. A
+// session recorded now keeps its full score, one recorded halfLife ago
+// three quarters of it and very old ones recencyFloor.
+func recencyFactor(recorded time.Time, now time.Time, halfLife time.Duration) float64 {
+	age := now.Sub(recorded)
+	if age < 0 {
+		age = 0
+	}
+	decay := math.Exp2(-float64(age) / float64(halfLife))
+	return recencyFloor + (1-recencyFloor)*decay
+}
+
+// recencyQuery multiplies the scores of q by the recency factor of the
+// matching sessions. Sessions without a recording date are treated as
+// very old.
+func recencyQuery(q query.Query, now time.Time, halfLife time.Duration) query.Query {
+	score := func(d *search.DocumentMatch) float64 {
+		recorded, ok := docRecorded(d)
+		if !ok {
+			return d.Score * recencyFloor
+		}
+		return d.Score * recencyFactor(recorded, now, halfLife)
+	}
+	return query.NewCustomScoreQueryWithScorer(q, score, []string{"recorded"}, nil)
+}
+
+// docRecorded returns the recording date loaded from the doc values. The
+// fields are reset afterwards as bleve only loads the stored fields of hits
+// without any.
+func docRecorded(d *search.DocumentMatch) (time.Time, bool) {
+	value, ok := d.Fields["recorded"].(string)
+	d.Fields = nil
+	if !ok {
+		return time.Time{}, false
+	}
+	recorded, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil || recorded.Year() <= 1 {
+		return time.Time{}, false
+	}
+	return recorded, true
+}
@@ -0,0 +1,44 @@
+package http
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHalfLife(t *testing.T) {
+	testcases := []struct {
+		value    string
+		expected time.Duration
+		invalid  bool
+	}{
+		{value: "0", expected: 0},
+		{value: "180d", expected: 180 * 24 * time.Hour},
+		{value: "2y", expected: time.Duration(2 * 365.25 * 24 * float64(time.Hour))},
+		{value: "8760h", expected: 8760 * time.Hour},
+		{value: "-1y", invalid: true},
+		{value: "soon", invalid: true},
+		{value: "y", invalid: true},
+	}
+	for _, testcase := range testcases {
+		t.Run(testcase.value, func(t *testing.T) {
+			halfLife, err := ParseHalfLife(testcase.value)
+			if testcase.invalid {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, testcase.expected, halfLife)
+		})
+	}
+}
+
+func TestRecencyFactor(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	halfLife := 365 * 24 * time.Hour
+	require.Equal(t, 1.0, recencyFactor(now, now, halfLife))
+	require.Equal(t, 1.0, recencyFactor(now.Add(time.Hour), now, halfLife))
+	require.InDelta(t, 0.75, recencyFactor(now.Add(-halfLife), now, halfLife), 1e-9)
+	require.InDelta(t, recencyFloor, recencyFactor(now.AddDate(-100, 0, 0), now, halfLife), 1e-9)
+}
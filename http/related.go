@@ -0,0 +1,112 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+	"github.com/julienschmidt/httprouter"
+	"github.com/zerok/pyvideosearch/index"
+)
+
+const defaultRelatedSize = 10
+
+// maxRelatedDescriptionWords limits how much of a description is used to
+// find related sessions. The beginning usually sums up the talk and longer
+// queries only slow down the search.
+const maxRelatedDescriptionWords = 50
+
+type relatedResponse struct {
+	Sessions []sessionSummary `json:"sessions"`
+}
+
+// handleRelated finds sessions similar to the given one based on its title,
+// description and speakers.
+func (s *server) handleRelated(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	size := defaultRelatedSize
+	if v := r.FormValue("size"); v != "" {
+		var err error
+		size, err = strconv.Atoi(v)
+		if err != nil || size < 1 || size > s.opts.MaxPageSize {
+			writeError(w, http.StatusBadRequest, "Invalid size: "+v)
+			return
+		}
+	}
+	otherConferences := false
+	if v := r.FormValue("other_conferences"); v != "" {
+		var err error
+		otherConferences, err = strconv.ParseBool(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid other_conferences: "+v)
+			return
+		}
+	}
+
+	id := index.SessionID(ps.ByName("collection"), ps.ByName("slug"))
+	s.idxLock.RLock()
+	defer s.idxLock.RUnlock()
+	doc, err := loadSession(s.idx.Index, id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load session")
+		return
+	}
+	if doc == nil {
+		writeError(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	req := bleve.NewSearchRequest(relatedQuery(doc, otherConferences))
+	req.Size = size
+	req.Fields = sessionFields
+	res, err := s.idx.Index.Search(req)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Query failed")
+		return
+	}
+	resp := relatedResponse{
+		Sessions: make([]sessionSummary, 0, len(res.Hits)),
+	}
+	for _, hit := range res.Hits {
+		resp.Sessions = append(resp.Sessions, newSessionSummary(hit))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// relatedQuery builds a weighted similarity query for doc. Matching titles
+// count the most, followed by shared speakers and the description.
+func relatedQuery(doc *sessionDocument, otherConferences bool) query.Query {
+	similar := make([]query.Query, 0, 4+len(doc.Speakers))
+	similar = append(similar, boostedMatch(doc.Title, "title", 3), boostedMatch(doc.Title, "description", 1.5))
+	if words := strings.Fields(doc.Description); len(words) > 0 {
+		if len(words) > maxRelatedDescriptionWords {
+			words = words[:maxRelatedDescriptionWords]
+		}
+		description := strings.Join(words, " ")
+		similar = append(similar, boostedMatch(description, "description", 1), boostedMatch(description, "title", 1))
+	}
+	for _, speaker := range doc.Speakers {
+		q := bleve.NewTermQuery(speaker.Slug)
+		q.SetField("speakers.slug")
+		q.SetBoost(2)
+		similar = append(similar, q)
+	}
+
+	q := bleve.NewBooleanQuery()
+	q.AddShould(similar...)
+	q.AddMustNot(bleve.NewDocIDQuery([]string{doc.ID}))
+	if otherConferences {
+		sameCollection := bleve.NewTermQuery(doc.CollectionSlug)
+		sameCollection.SetField("collection_slug")
+		q.AddMustNot(sameCollection)
+	}
+	return q
+}
+
+func boostedMatch(text string, field string, boost float64) query.Query {
+	q := bleve.NewMatchQuery(text)
+	q.SetField(field)
+	q.SetBoost(boost)
+	return q
+}
@@ -0,0 +1,434 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search"
+	"github.com/blevesearch/bleve/v2/search/highlight/highlighter/ansi"
+	"github.com/blevesearch/bleve/v2/search/highlight/highlighter/html"
+	"github.com/blevesearch/bleve/v2/search/query"
+	"github.com/julienschmidt/httprouter"
+	"github.com/zerok/pyvideosearch/slugify"
+)
+
+var dateParamFormats = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"}
+
+const defaultSort = "relevance"
+
+const highlightNone = "none"
+
+// debugExplain requests bleve's explanation of the score of every hit.
+const debugExplain = "explain"
+
+// highlightFields are the fields for which fragments are returned if
+// highlighting is requested. The HTML highlighter escapes the source text.
+var highlightFields = []string{"title", "description"}
+
+// sortOrders maps the values of the sort parameter to bleve sort orders.
+// Every order ends with the document ID so that ties are broken
+// deterministically and cursors point at exactly one position.
+var sortOrders = map[string]func() search.SortOrder{
+	"relevance": func() search.SortOrder {
+		return search.SortOrder{&search.SortScore{Desc: true}, &search.SortDocID{}}
+	},
+	"newest": func() search.SortOrder {
+		return search.SortOrder{recordedSort(true), &search.SortScore{Desc: true}, &search.SortDocID{}}
+	},
+	"oldest": func() search.SortOrder {
+		return search.SortOrder{recordedSort(false), &search.SortScore{Desc: true}, &search.SortDocID{}}
+	},
+	"title": func() search.SortOrder {
+		return search.SortOrder{
+			&search.SortField{Field: "title_sort", Type: search.SortFieldAsString},
+			&search.SortScore{Desc: true},
+			&search.SortDocID{},
+		}
+	},
+}
+
+// recordedSort orders by recording date. The type is left on auto so that
+// the raw sort values of sessions without a date can be used in cursors.
+func recordedSort(desc bool) search.SearchSort {
+	return &search.SortField{
+		Field:   "recorded",
+		Missing: search.SortFieldMissingLast,
+		Desc:    desc,
+	}
+}
+
+// searchParams holds everything a client can pass to the search endpoint.
+type searchParams struct {
+	Query        string
+	Speaker      string
+	Collection   string
+	RecordedFrom time.Time
+	RecordedTo   time.Time
+	HasVideo     *bool
+	Tags         []string
+	Language     string
+	DurationMin  *float64
+	DurationMax  *float64
+	Sort         string
+	Highlight    string
+	Explain      bool
+	// RecencyHalfLife overrides the half-life of the recency boost
+	// configured for the server.
+	RecencyHalfLife *time.Duration
+	// fuzzyTerms replaces the free-text query with a fuzzy match of the
+	// terms if the query itself didn't find anything.
+	fuzzyTerms []string
+}
+
+func parseSearchParams(r *http.Request) (*searchParams, error) {
+	p := &searchParams{
+		Query:      r.FormValue("q"),
+		Speaker:    r.FormValue("speaker"),
+		Collection: r.FormValue("collection"),
+		Language:   r.FormValue("language"),
+		Sort:       r.FormValue("sort"),
+	}
+	// FormValue has parsed the form already.
+	p.Tags = r.Form["tag"]
+	if p.Sort == "" {
+		p.Sort = defaultSort
+	}
+	if _, ok := sortOrders[p.Sort]; !ok {
+		return nil, fmt.Errorf("Invalid sort: %s", p.Sort)
+	}
+	switch p.Highlight = r.FormValue("highlight"); p.Highlight {
+	case "", highlightNone:
+		p.Highlight = highlightNone
+	case html.Name, ansi.Name:
+	default:
+		return nil, fmt.Errorf("Invalid highlight: %s", p.Highlight)
+	}
+	switch v := r.FormValue("debug"); v {
+	case "":
+	case debugExplain:
+		p.Explain = true
+	default:
+		return nil, fmt.Errorf("Invalid debug: %s", v)
+	}
+	var err error
+	if v := r.FormValue("recorded_from"); v != "" {
+		if p.RecordedFrom, _, err = parseDateParam(v); err != nil {
+			return nil, fmt.Errorf("Invalid recorded_from: %s", v)
+		}
+	}
+	if v := r.FormValue("recorded_to"); v != "" {
+		var dateOnly bool
+		if p.RecordedTo, dateOnly, err = parseDateParam(v); err != nil {
+			return nil, fmt.Errorf("Invalid recorded_to: %s", v)
+		}
+		// A plain date should include everything recorded on that day.
+		if dateOnly {
+			p.RecordedTo = p.RecordedTo.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+	}
+	if !p.RecordedFrom.IsZero() && !p.RecordedTo.IsZero() && p.RecordedTo.Before(p.RecordedFrom) {
+		return nil, fmt.Errorf("recorded_to must not be before recorded_from")
+	}
+	if v := r.FormValue("has_video"); v != "" {
+		hasVideo, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid has_video: %s", v)
+		}
+		p.HasVideo = &hasVideo
+	}
+	if v := r.FormValue("recency_half_life"); v != "" {
+		halfLife, err := ParseHalfLife(v)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid recency_half_life: %s", v)
+		}
+		p.RecencyHalfLife = &halfLife
+	}
+	if p.DurationMin, err = parseDurationParam(r, "duration_min"); err != nil {
+		return nil, err
+	}
+	if p.DurationMax, err = parseDurationParam(r, "duration_max"); err != nil {
+		return nil, err
+	}
+	if p.DurationMin != nil && p.DurationMax != nil && *p.DurationMax < *p.DurationMin {
+		return nil, fmt.Errorf("duration_max must not be less than duration_min")
+	}
+	return p, nil
+}
+
+func parseDateParam(v string) (time.Time, bool, error) {
+	var err error
+	for _, format := range dateParamFormats {
+		var t time.Time
+		t, err = time.Parse(format, v)
+		if err == nil {
+			return t, len(v) == len("2006-01-02"), nil
+		}
+	}
+	return time.Time{}, false, err
+}
+
+// parseDurationParam parses a duration in seconds. It returns nil if the
+// parameter is missing.
+func parseDurationParam(r *http.Request, name string) (*float64, error) {
+	v := r.FormValue(name)
+	if v == "" {
+		return nil, nil
+	}
+	seconds, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s: %s", name, v)
+	}
+	duration := float64(seconds)
+	return &duration, nil
+}
+
+// buildQuery combines the free-text query and all the structured filters
+// into a single conjunction.
+func (p *searchParams) buildQuery(cfg queryConfig) query.Query {
+	conjuncts := make([]query.Query, 0, 8+len(p.Tags))
+	switch {
+	case len(p.fuzzyTerms) > 0:
+		conjuncts = append(conjuncts, fuzzyTextQuery(p.fuzzyTerms))
+	case p.Query != "":
+		conjuncts = append(conjuncts, textQuery(p.Query, cfg))
+	}
+	if p.Speaker != "" {
+		q := bleve.NewTermQuery(slugify.Slugify(p.Speaker))
+		q.SetField("speakers.slug")
+		conjuncts = append(conjuncts, q)
+	}
+	if p.Collection != "" {
+		q := bleve.NewTermQuery(slugify.Slugify(p.Collection))
+		q.SetField("collection_slug")
+		conjuncts = append(conjuncts, q)
+	}
+	if !p.RecordedFrom.IsZero() || !p.RecordedTo.IsZero() {
+		inclusive := true
+		q := bleve.NewDateRangeInclusiveQuery(p.RecordedFrom, p.RecordedTo, &inclusive, &inclusive)
+		q.SetField("recorded")
+		conjuncts = append(conjuncts, q)
+	}
+	if p.HasVideo != nil {
+		q := bleve.NewBoolFieldQuery(*p.HasVideo)
+		q.SetField("has_video")
+		conjuncts = append(conjuncts, q)
+	}
+	// Sessions have to carry all of the requested tags.
+	for _, tag := range p.Tags {
+		q := bleve.NewTermQuery(tag)
+		q.SetField("tags")
+		conjuncts = append(conjuncts, q)
+	}
+	if p.Language != "" {
+		q := bleve.NewTermQuery(p.Language)
+		q.SetField("language")
+		conjuncts = append(conjuncts, q)
+	}
+	if p.DurationMin != nil || p.DurationMax != nil {
+		// Sessions without a known duration are indexed with 0 and must not
+		// match an upper bound only.
+		min := p.DurationMin
+		if min == nil {
+			known := 1.0
+			min = &known
+		}
+		inclusive := true
+		q := bleve.NewNumericRangeInclusiveQuery(min, p.DurationMax, &inclusive, &inclusive)
+		q.SetField("duration")
+		conjuncts = append(conjuncts, q)
+	}
+	var q query.Query
+	switch len(conjuncts) {
+	case 0:
+		return bleve.NewMatchNoneQuery()
+	case 1:
+		q = conjuncts[0]
+	default:
+		q = bleve.NewConjunctionQuery(conjuncts...)
+	}
+	halfLife := cfg.recencyHalfLife
+	if p.RecencyHalfLife != nil {
+		halfLife = *p.RecencyHalfLife
+	}
+	if halfLife > 0 {
+		q = recencyQuery(q, time.Now(), halfLife)
+	}
+	return q
+}
+
+// newYearFacet creates a facet over the recording date with one bucket per
+// year between oldest and newest. It returns nil if there are no recording
+// dates at all.
+func newYearFacet(oldest, newest time.Time) *bleve.FacetRequest {
+	if oldest.IsZero() || newest.IsZero() {
+		return nil
+	}
+	years := newest.Year() - oldest.Year() + 1
+	facet := bleve.NewFacetRequest("recorded", years)
+	for year := oldest.Year(); year <= newest.Year(); year++ {
+		start := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+		facet.AddDateTimeRange(strconv.Itoa(year), start, start.AddDate(1, 0, 0))
+	}
+	return facet
+}
+
+// durationRanges are the buckets of the duration facet in seconds. Sessions
+// without a known duration are not counted.
+var durationRanges = []struct {
+	name     string
+	min, max float64
+}{
+	{"short", 1, 15 * 60},
+	{"medium", 15 * 60, 45 * 60},
+	{"long", 45 * 60, 0},
+}
+
+func newDurationFacet() *bleve.FacetRequest {
+	facet := bleve.NewFacetRequest("duration", len(durationRanges))
+	for _, r := range durationRanges {
+		min, max := r.min, r.max
+		if max == 0 {
+			facet.AddNumericRange(r.name, &min, nil)
+		} else {
+			facet.AddNumericRange(r.name, &min, &max)
+		}
+	}
+	return facet
+}
+
+// searchError carries the HTTP status that should be reported for a failed
+// search.
+type searchError struct {
+	status int
+	msg    string
+}
+
+func (e *searchError) Error() string {
+	return e.msg
+}
+
+// executedSearch bundles everything needed to render the response of a
+// search in any of the API versions.
+type executedSearch struct {
+	params *searchParams
+	paging *pagingParams
+	req    *bleve.SearchRequest
+	res    *bleve.SearchResult
+	// corrected is set if the results are those of the fuzzy fallback.
+	corrected  bool
+	didYouMean string
+}
+
+// v1SearchFields are the stored fields returned by the first version of
+// the search API.
+var v1SearchFields = []string{"title", "url", "conference", "speakers.name", "speakers.slug", "thumbnail_url", "collection_title", "collection_url", "recorded", "recorded_formatted", "tags", "language", "duration"}
+
+// executeSearch runs the search described by the parameters of r and
+// returns the stored fields listed in fields with every hit.
+func (s *server) executeSearch(r *http.Request, fields []string) (*executedSearch, *searchError) {
+	searchQueries.Add(1)
+	params, err := parseSearchParams(r)
+	if err != nil {
+		return nil, &searchError{http.StatusBadRequest, err.Error()}
+	}
+	pagingParams, err := parsePagingParams(r, s.opts.MaxPageSize, params.Sort)
+	if err != nil {
+		return nil, &searchError{http.StatusBadRequest, err.Error()}
+	}
+	req := bleve.NewSearchRequest(params.buildQuery(s.queryConfig()))
+	req.Fields = fields
+	req.SortByCustom(sortOrders[params.Sort]())
+	pagingParams.apply(req)
+	req.IncludeLocations = true
+	req.Explain = params.Explain
+	if params.Highlight != highlightNone {
+		req.Highlight = bleve.NewHighlightWithStyle(params.Highlight)
+		req.Highlight.Fields = highlightFields
+	}
+	collectionFacet := bleve.NewFacetRequest("collection_title", 10)
+	speakerFacet := bleve.NewFacetRequest("speakers.name", 10)
+	req.AddFacet("speaker", speakerFacet)
+	req.AddFacet("collection", collectionFacet)
+	req.AddFacet("tag", bleve.NewFacetRequest("tags", 10))
+	req.AddFacet("language", bleve.NewFacetRequest("language", 10))
+	req.AddFacet("duration", newDurationFacet())
+	s.idxLock.RLock()
+	defer s.idxLock.RUnlock()
+	oldest, newest, err := s.idx.RecordedRange()
+	if err != nil {
+		return nil, &searchError{http.StatusInternalServerError, "Query failed"}
+	}
+	if yearFacet := newYearFacet(oldest, newest); yearFacet != nil {
+		req.AddFacet("year", yearFacet)
+	}
+	if err := req.Validate(); err != nil {
+		return nil, &searchError{http.StatusBadRequest, fmt.Sprintf("Invalid query: %s", err.Error())}
+	}
+	res, err := s.idx.Index.Search(req)
+	if err != nil {
+		return nil, &searchError{http.StatusInternalServerError, "Query failed"}
+	}
+	es := &executedSearch{
+		params: params,
+		paging: pagingParams,
+		req:    req,
+		res:    res,
+	}
+	if res.Total == 0 && params.Query != "" && isPlainQuery(params.Query) {
+		if err := s.searchFuzzy(es); err != nil {
+			return nil, &searchError{http.StatusInternalServerError, "Query failed"}
+		}
+	}
+	return es, nil
+}
+
+// searchFuzzy retries a search that didn't find anything with fuzzy
+// matching of the query terms. The results are only replaced if the fuzzy
+// search finds something.
+func (s *server) searchFuzzy(es *executedSearch) error {
+	terms := analyzeQuery(s.idx.Index, es.params.Query)
+	if len(terms) == 0 {
+		return nil
+	}
+	var err error
+	if es.didYouMean, err = didYouMean(s.idx.Index, es.params.Query, terms); err != nil {
+		return err
+	}
+	es.params.fuzzyTerms = terms
+	es.req.Query = es.params.buildQuery(s.queryConfig())
+	res, err := s.idx.Index.Search(es.req)
+	if err != nil {
+		return err
+	}
+	if res.Total > 0 {
+		es.res = res
+		es.corrected = true
+	}
+	return nil
+}
+
+// searchResponse is returned by the first version of the search API. It
+// exposes bleve's search result as-is.
+type searchResponse struct {
+	*bleve.SearchResult
+	Paging     paging `json:"paging"`
+	Corrected  bool   `json:"corrected,omitempty"`
+	DidYouMean string `json:"did_you_mean,omitempty"`
+}
+
+func (s *server) handleSearch(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	es, err := s.executeSearch(r, v1SearchFields)
+	if err != nil {
+		writeError(w, err.status, err.msg)
+		return
+	}
+	writeJSON(w, http.StatusOK, searchResponse{
+		SearchResult: es.res,
+		Paging:       newPaging(r, es.paging, es.req, es.res),
+		Corrected:    es.corrected,
+		DidYouMean:   es.didYouMean,
+	})
+}
@@ -0,0 +1,368 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/stretchr/testify/require"
+	"github.com/zerok/pyvideosearch/index"
+	"github.com/zerok/pyvideosearch/synonyms"
+)
+
+var testSessions = map[string]index.IndexedSession{
+	"session:pycon-2016:asyncio-basics": {
+		Title:           "Asyncio basics",
+		Description:     "An introduction to asyncio",
+		URL:             "/pycon-2016/asyncio-basics.html",
+		CollectionTitle: "PyCon 2016",
+		CollectionSlug:  "pycon-2016",
+		Speakers:        []index.Speaker{{Name: "Jane Doe", Slug: "jane-doe"}},
+		Recorded:        time.Date(2016, 5, 30, 0, 0, 0, 0, time.UTC),
+		HasVideo:        true,
+		Videos:          []index.Video{{Type: "youtube", URL: "https://www.youtube.com/watch?v=asyncio"}},
+		Tags:            []string{"asyncio", "beginner"},
+		Language:        "English",
+		Duration:        25 * 60,
+	},
+	"session:pycon-2018:advanced-asyncio": {
+		Title:           "Advanced asyncio",
+		Description:     "Going deeper into asyncio",
+		URL:             "/pycon-2018/advanced-asyncio.html",
+		CollectionTitle: "PyCon 2018",
+		CollectionSlug:  "pycon-2018",
+		Speakers:        []index.Speaker{{Name: "John Smith", Slug: "john-smith"}},
+		Recorded:        time.Date(2018, 5, 11, 0, 0, 0, 0, time.UTC),
+		HasVideo:        false,
+		Tags:            []string{"asyncio"},
+		Language:        "English",
+		Duration:        50 * 60,
+	},
+	"session:pycon-2018:packaging": {
+		Title:           "Packaging Python projects",
+		Description:     "Everything about asyncio-free packaging",
+		URL:             "/pycon-2018/packaging.html",
+		CollectionTitle: "PyCon 2018",
+		CollectionSlug:  "pycon-2018",
+		Speakers:        []index.Speaker{{Name: "Jane Doe", Slug: "jane-doe"}},
+		HasVideo:        true,
+	},
+}
+
+func newTestServer(t *testing.T) *server {
+	return newTestServerWith(t, testSessions)
+}
+
+func newTestServerWith(t *testing.T, sessions map[string]index.IndexedSession) *server {
+	idx, err := bleve.NewMemOnly(index.NewMapping())
+	require.NoError(t, err)
+	for id, session := range sessions {
+		require.NoError(t, idx.Index(id, session))
+	}
+	t.Cleanup(func() { idx.Close() })
+	return newServer(&index.Index{Index: idx}, Options{})
+}
+
+func doSearch(t *testing.T, srv *server, query string) (int, map[string]interface{}) {
+	rec := httptest.NewRecorder()
+	srv.router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/search?"+query, nil))
+	return rec.Code, decodeBody(t, rec)
+}
+
+func decodeBody(t *testing.T, rec *httptest.ResponseRecorder) map[string]interface{} {
+	body := map[string]interface{}{}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	return body
+}
+
+func TestSearchFilters(t *testing.T) {
+	srv := newTestServer(t)
+	testcases := []struct {
+		query string
+		total float64
+	}{
+		{query: "q=asyncio", total: 3},
+		{query: "q=asyncio&speaker=Jane+Doe", total: 2},
+		{query: "q=asyncio&speaker=john-smith", total: 1},
+		{query: "collection=pycon-2018", total: 2},
+		{query: "q=asyncio&recorded_from=2017-01-01", total: 1},
+		{query: "q=asyncio&recorded_to=2016-05-30", total: 1},
+		{query: "q=asyncio&has_video=true", total: 2},
+		{query: "q=asyncio&has_video=false&speaker=jane-doe", total: 0},
+		{query: "q=asyncio&tag=asyncio", total: 2},
+		{query: "q=asyncio&tag=asyncio&tag=beginner", total: 1},
+		{query: "language=English", total: 2},
+		{query: "q=asyncio&duration_min=1800", total: 1},
+		{query: "q=asyncio&duration_max=1800", total: 1},
+		{query: "q=asyncio&duration_min=1000&duration_max=3000", total: 2},
+	}
+	for _, testcase := range testcases {
+		t.Run(testcase.query, func(t *testing.T) {
+			status, body := doSearch(t, srv, testcase.query)
+			require.Equal(t, http.StatusOK, status)
+			require.Equal(t, testcase.total, body["total_hits"])
+		})
+	}
+}
+
+func TestSearchLanguageAnalysis(t *testing.T) {
+	srv := newTestServerWith(t, map[string]index.IndexedSession{
+		"session:pycon-es-2019:ml": {
+			Title:            "Modelos automáticos de aprendizajes profundos",
+			CollectionSlug:   "pycon-es-2019",
+			AnalysisLanguage: "es",
+		},
+		"session:pycon-2019:ml": {
+			Title:            "Deep learning models",
+			CollectionSlug:   "pycon-2019",
+			AnalysisLanguage: "en",
+		},
+	})
+	testcases := []struct {
+		query    string
+		expected string
+	}{
+		{query: "q=aprendizaje+autom%C3%A1tico", expected: "session:pycon-es-2019:ml"},
+		{query: "q=learns", expected: "session:pycon-2019:ml"},
+	}
+	for _, testcase := range testcases {
+		t.Run(testcase.query, func(t *testing.T) {
+			status, body := doSearch(t, srv, testcase.query)
+			require.Equal(t, http.StatusOK, status)
+			require.Equal(t, float64(1), body["total_hits"])
+			require.Equal(t, testcase.expected, hitID(body, 0))
+		})
+	}
+}
+
+func TestSearchSynonyms(t *testing.T) {
+	srv := newTestServerWith(t, map[string]index.IndexedSession{
+		"session:pycon-2019:ml": {
+			Title:          "Machine Learning for everyone",
+			CollectionSlug: "pycon-2019",
+		},
+		"session:pycon-2019:k8s": {
+			Title:          "Running Python on k8s",
+			CollectionSlug: "pycon-2019",
+		},
+	})
+	syn, err := synonyms.Parse([]byte("- [ml, machine learning]\n- [k8s, kubernetes]"))
+	require.NoError(t, err)
+	srv.opts.Synonyms = syn
+	testcases := []struct {
+		query    string
+		expected string
+	}{
+		{query: "q=ML", expected: "session:pycon-2019:ml"},
+		{query: "q=kubernetes", expected: "session:pycon-2019:k8s"},
+	}
+	for _, testcase := range testcases {
+		t.Run(testcase.query, func(t *testing.T) {
+			status, body := doSearch(t, srv, testcase.query)
+			require.Equal(t, http.StatusOK, status)
+			require.Equal(t, float64(1), body["total_hits"])
+			require.Equal(t, testcase.expected, hitID(body, 0))
+		})
+	}
+}
+
+func TestSearchFuzzyFallback(t *testing.T) {
+	srv := newTestServer(t)
+	testcases := []struct {
+		query      string
+		total      float64
+		corrected  bool
+		didYouMean string
+	}{
+		{query: "q=asyncio", total: 3},
+		{query: "q=asyncoi", total: 3, corrected: true, didYouMean: "asyncio"},
+		{query: "q=the+pakaging", total: 1, corrected: true, didYouMean: "the packaging"},
+		{query: "q=pakaging&speaker=john-smith", total: 0, didYouMean: "packaging"},
+		{query: "q=title:asyncoi", total: 0},
+		{query: "q=xyzzyqwerty", total: 0},
+	}
+	for _, testcase := range testcases {
+		t.Run(testcase.query, func(t *testing.T) {
+			status, body := doSearch(t, srv, testcase.query)
+			require.Equal(t, http.StatusOK, status)
+			require.Equal(t, testcase.total, body["total_hits"])
+			if testcase.corrected {
+				require.Equal(t, true, body["corrected"])
+			} else {
+				require.NotContains(t, body, "corrected")
+			}
+			if testcase.didYouMean != "" {
+				require.Equal(t, testcase.didYouMean, body["did_you_mean"])
+			} else {
+				require.NotContains(t, body, "did_you_mean")
+			}
+		})
+	}
+}
+
+func TestSearchFieldBoosts(t *testing.T) {
+	srv := newTestServerWith(t, map[string]index.IndexedSession{
+		"session:pycon-2019:title": {
+			Title:       "Property-based testing",
+			Description: "Let the computer come up with examples",
+		},
+		"session:pycon-2019:description": {
+			Title:       "Hypothesis",
+			Description: "A library for property-based testing of Python code",
+		},
+	})
+	status, body := doSearch(t, srv, "q=testing")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "session:pycon-2019:title", hitID(body, 0))
+
+	boosts := FieldBoosts{Title: 1, Description: 10}
+	srv.opts.Boosts = &boosts
+	status, body = doSearch(t, srv, "q=testing")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "session:pycon-2019:description", hitID(body, 0))
+}
+
+func TestSearchRecency(t *testing.T) {
+	srv := newTestServerWith(t, map[string]index.IndexedSession{
+		"session:pycon-2010:testing": {
+			Title:    "Testing Python code",
+			Recorded: time.Date(2010, 3, 1, 0, 0, 0, 0, time.UTC),
+		},
+		"session:pycon-2024:testing": {
+			Title:    "Testing Python code",
+			Recorded: time.Now().AddDate(0, -1, 0),
+		},
+		"session:pycon-undated:testing": {
+			Title: "Testing Python code",
+		},
+	})
+	// Without the recency boost the scores are equal and the IDs decide.
+	status, body := doSearch(t, srv, "q=testing")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "session:pycon-2010:testing", hitID(body, 0))
+
+	srv.opts.RecencyHalfLife = 2 * 365 * 24 * time.Hour
+	status, body = doSearch(t, srv, "q=testing")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "session:pycon-2024:testing", hitID(body, 0))
+	require.Equal(t, "session:pycon-2010:testing", hitID(body, 1))
+	require.Equal(t, "session:pycon-undated:testing", hitID(body, 2))
+	fields := body["hits"].([]interface{})[0].(map[string]interface{})["fields"].(map[string]interface{})
+	require.IsType(t, "", fields["recorded"])
+
+	// The half-life can be overridden per request.
+	status, body = doSearch(t, srv, "q=testing&recency_half_life=0")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "session:pycon-2010:testing", hitID(body, 0))
+}
+
+func TestSearchExplain(t *testing.T) {
+	srv := newTestServer(t)
+	status, body := doSearch(t, srv, "q=asyncio")
+	require.Equal(t, http.StatusOK, status)
+	require.NotContains(t, body["hits"].([]interface{})[0], "explanation")
+
+	status, body = doSearch(t, srv, "q=asyncio&debug=explain")
+	require.Equal(t, http.StatusOK, status)
+	require.Contains(t, body["hits"].([]interface{})[0], "explanation")
+}
+
+func TestSearchInvalidParameters(t *testing.T) {
+	srv := newTestServer(t)
+	for _, query := range []string{
+		"q=asyncio&has_video=maybe",
+		"q=asyncio&recorded_from=yesterday",
+		"q=asyncio&recorded_from=2018-01-01&recorded_to=2017-01-01",
+		"q=asyncio&duration_min=-1",
+		"q=asyncio&debug=verbose",
+		"q=asyncio&recency_half_life=recent",
+		"q=asyncio&duration_min=600&duration_max=300",
+		"q=%22unterminated",
+	} {
+		t.Run(query, func(t *testing.T) {
+			status, body := doSearch(t, srv, query)
+			require.Equal(t, http.StatusBadRequest, status)
+			require.NotEmpty(t, body["error"])
+		})
+	}
+}
+
+func TestSearchSort(t *testing.T) {
+	srv := newTestServer(t)
+	testcases := []struct {
+		sort     string
+		expected []string
+	}{
+		{
+			sort:     "newest",
+			expected: []string{"session:pycon-2018:advanced-asyncio", "session:pycon-2016:asyncio-basics", "session:pycon-2018:packaging"},
+		},
+		{
+			sort:     "oldest",
+			expected: []string{"session:pycon-2016:asyncio-basics", "session:pycon-2018:advanced-asyncio", "session:pycon-2018:packaging"},
+		},
+	}
+	for _, testcase := range testcases {
+		t.Run(testcase.sort, func(t *testing.T) {
+			status, body := doSearch(t, srv, "q=asyncio&sort="+testcase.sort)
+			require.Equal(t, http.StatusOK, status)
+			for i, id := range testcase.expected {
+				require.Equal(t, id, hitID(body, i))
+			}
+		})
+	}
+
+	status, _ := doSearch(t, srv, "q=asyncio&sort=random")
+	require.Equal(t, http.StatusBadRequest, status)
+}
+
+// TestSearchSortTitle checks that sessions are sorted by their whole title
+// regardless of case and not by one of its words.
+func TestSearchSortTitle(t *testing.T) {
+	srv := newTestServerWith(t, map[string]index.IndexedSession{
+		"session:conf:building": {Title: "Building APIs with asyncio"},
+		"session:conf:depth":    {Title: "asyncio in depth"},
+		"session:conf:loop":     {Title: "Understanding the event loop", Description: "How asyncio works"},
+	})
+	status, body := doSearch(t, srv, "q=asyncio&sort=title")
+	require.Equal(t, http.StatusOK, status)
+	for i, id := range []string{"session:conf:depth", "session:conf:building", "session:conf:loop"} {
+		require.Equal(t, id, hitID(body, i))
+	}
+}
+
+func TestSearchYearFacet(t *testing.T) {
+	srv := newTestServer(t)
+	status, body := doSearch(t, srv, "q=asyncio")
+	require.Equal(t, http.StatusOK, status)
+	facet := body["facets"].(map[string]interface{})["year"].(map[string]interface{})
+	counts := map[string]float64{}
+	for _, r := range facet["date_ranges"].([]interface{}) {
+		r := r.(map[string]interface{})
+		counts[r["name"].(string)] = r["count"].(float64)
+	}
+	require.Equal(t, map[string]float64{"2016": 1, "2018": 1}, counts)
+}
+
+func TestSearchHighlight(t *testing.T) {
+	srv := newTestServer(t)
+	srv.idx.Index.Index("session:europython-2019:tags", index.IndexedSession{
+		Title:       "Escaping <b>asyncio</b> & friends",
+		Description: "Nothing to see",
+	})
+
+	status, body := doSearch(t, srv, "q=escaping&highlight=html")
+	require.Equal(t, http.StatusOK, status)
+	fragments := body["hits"].([]interface{})[0].(map[string]interface{})["fragments"].(map[string]interface{})
+	require.Equal(t, []interface{}{"<mark>Escaping</mark> &lt;b&gt;asyncio&lt;/b&gt; &amp; friends"}, fragments["title"])
+
+	status, body = doSearch(t, srv, "q=escaping")
+	require.Equal(t, http.StatusOK, status)
+	require.Nil(t, body["hits"].([]interface{})[0].(map[string]interface{})["fragments"])
+
+	status, _ = doSearch(t, srv, "q=escaping&highlight=bold")
+	require.Equal(t, http.StatusBadRequest, status)
+}
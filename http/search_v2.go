@@ -0,0 +1,188 @@
+package http
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/blevesearch/bleve/v2/search"
+	"github.com/julienschmidt/httprouter"
+)
+
+// The types in this file define the public contract of /api/v2/search.
+// Unlike version 1 they don't expose any of bleve's types so that
+// upgrading bleve doesn't change the shape of the API.
+
+type searchResponseV2 struct {
+	Hits   []searchHitV2  `json:"hits"`
+	Facets searchFacetsV2 `json:"facets"`
+	Paging paging         `json:"paging"`
+	// Corrected is set if nothing matched the query and the hits are
+	// those of a fuzzy search instead.
+	Corrected  bool   `json:"corrected"`
+	DidYouMean string `json:"did_you_mean,omitempty"`
+}
+
+type searchHitV2 struct {
+	sessionSummary
+	Score       float64             `json:"score"`
+	Fragments   map[string][]string `json:"fragments,omitempty"`
+	Explanation *explanationV2      `json:"explanation,omitempty"`
+}
+
+// explanationV2 breaks down how the score of a hit was calculated. It is
+// only returned with debug=explain.
+type explanationV2 struct {
+	Value    float64         `json:"value"`
+	Message  string          `json:"message"`
+	Children []explanationV2 `json:"children,omitempty"`
+}
+
+func newExplanationV2(expl *search.Explanation) *explanationV2 {
+	if expl == nil {
+		return nil
+	}
+	e := &explanationV2{Value: expl.Value, Message: expl.Message}
+	for _, child := range expl.Children {
+		if c := newExplanationV2(child); c != nil {
+			e.Children = append(e.Children, *c)
+		}
+	}
+	return e
+}
+
+type searchFacetsV2 struct {
+	Speakers    []termFacetV2         `json:"speakers"`
+	Collections []termFacetV2         `json:"collections"`
+	Years       []rangeFacetV2        `json:"years"`
+	Tags        []termFacetV2         `json:"tags"`
+	Languages   []termFacetV2         `json:"languages"`
+	Durations   []numericRangeFacetV2 `json:"durations"`
+}
+
+type termFacetV2 struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+type rangeFacetV2 struct {
+	Name  string `json:"name"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Count int    `json:"count"`
+}
+
+// numericRangeFacetV2 reports the number of sessions within a range of
+// durations in seconds. Max is left out for the open-ended range.
+type numericRangeFacetV2 struct {
+	Name  string   `json:"name"`
+	Min   *float64 `json:"min,omitempty"`
+	Max   *float64 `json:"max,omitempty"`
+	Count int      `json:"count"`
+}
+
+func (s *server) handleSearchV2(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	es, err := s.executeSearch(r, sessionFields)
+	if err != nil {
+		writeError(w, err.status, err.msg)
+		return
+	}
+	writeJSON(w, http.StatusOK, newSearchResponseV2(r, es))
+}
+
+func newSearchResponseV2(r *http.Request, es *executedSearch) searchResponseV2 {
+	resp := searchResponseV2{
+		Hits: make([]searchHitV2, 0, len(es.res.Hits)),
+		Facets: searchFacetsV2{
+			Speakers:    newTermFacetV2(es.res.Facets["speaker"]),
+			Collections: newTermFacetV2(es.res.Facets["collection"]),
+			Years:       newRangeFacetV2(es.res.Facets["year"]),
+			Tags:        newTermFacetV2(es.res.Facets["tag"]),
+			Languages:   newTermFacetV2(es.res.Facets["language"]),
+			Durations:   newNumericRangeFacetV2(es.res.Facets["duration"]),
+		},
+		Paging:     newPaging(r, es.paging, es.req, es.res),
+		Corrected:  es.corrected,
+		DidYouMean: es.didYouMean,
+	}
+	for _, hit := range es.res.Hits {
+		h := searchHitV2{
+			sessionSummary: newSessionSummary(hit),
+			Score:          hit.Score,
+			Explanation:    newExplanationV2(hit.Expl),
+		}
+		if len(hit.Fragments) > 0 {
+			h.Fragments = hit.Fragments
+		}
+		resp.Hits = append(resp.Hits, h)
+	}
+	return resp
+}
+
+// newTermFacetV2 orders the terms by their count. Terms with the same count
+// are ordered alphabetically to keep the output stable. The empty term
+// counts sessions without a value and is left out.
+func newTermFacetV2(result *search.FacetResult) []termFacetV2 {
+	facet := make([]termFacetV2, 0, 10)
+	if result == nil || result.Terms == nil {
+		return facet
+	}
+	for _, term := range result.Terms.Terms() {
+		if term.Term == "" {
+			continue
+		}
+		facet = append(facet, termFacetV2{Value: term.Term, Count: term.Count})
+	}
+	sort.SliceStable(facet, func(i, j int) bool {
+		if facet[i].Count != facet[j].Count {
+			return facet[i].Count > facet[j].Count
+		}
+		return facet[i].Value < facet[j].Value
+	})
+	return facet
+}
+
+// newRangeFacetV2 orders the date ranges chronologically.
+func newRangeFacetV2(result *search.FacetResult) []rangeFacetV2 {
+	facet := make([]rangeFacetV2, 0, 10)
+	if result == nil {
+		return facet
+	}
+	for _, r := range result.DateRanges {
+		f := rangeFacetV2{Name: r.Name, Count: r.Count}
+		if r.Start != nil {
+			f.From = formatFacetDate(*r.Start)
+		}
+		if r.End != nil {
+			f.To = formatFacetDate(*r.End)
+		}
+		facet = append(facet, f)
+	}
+	sort.Slice(facet, func(i, j int) bool {
+		return facet[i].From < facet[j].From
+	})
+	return facet
+}
+
+// newNumericRangeFacetV2 orders the numeric ranges by their lower bound.
+func newNumericRangeFacetV2(result *search.FacetResult) []numericRangeFacetV2 {
+	facet := make([]numericRangeFacetV2, 0, len(durationRanges))
+	if result == nil {
+		return facet
+	}
+	for _, r := range result.NumericRanges {
+		facet = append(facet, numericRangeFacetV2{Name: r.Name, Min: r.Min, Max: r.Max, Count: r.Count})
+	}
+	sort.Slice(facet, func(i, j int) bool {
+		return facet[i].Min != nil && (facet[j].Min == nil || *facet[i].Min < *facet[j].Min)
+	})
+	return facet
+}
+
+func formatFacetDate(value string) string {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return value
+	}
+	return t.UTC().Format(time.RFC3339)
+}
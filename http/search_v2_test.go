@@ -0,0 +1,80 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var updateGolden = flag.Bool("update", false, "Update the golden files of the API tests")
+
+// TestSearchV2Golden pins the response format of /api/v2/search. If the
+// format changes on purpose, regenerate the files with
+// go test ./http -run TestSearchV2Golden -update
+func TestSearchV2Golden(t *testing.T) {
+	testcases := []struct {
+		name  string
+		query string
+	}{
+		{name: "newest", query: "q=asyncio&sort=newest"},
+		{name: "filters", query: "q=asyncio&speaker=jane-doe&has_video=true"},
+		{name: "highlight", query: "q=basics&highlight=html"},
+		{name: "paging", query: "q=asyncio&sort=oldest&size=1&page=2"},
+		{name: "invalid", query: "q=asyncio&size=0"},
+	}
+	srv := newTestServer(t)
+	for _, testcase := range testcases {
+		t.Run(testcase.name, func(t *testing.T) {
+			rec := serve(srv, "/api/v2/search?"+testcase.query)
+			var body interface{}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			var actual bytes.Buffer
+			enc := json.NewEncoder(&actual)
+			enc.SetEscapeHTML(false)
+			enc.SetIndent("", "  ")
+			require.NoError(t, enc.Encode(normalizeGolden(body)))
+
+			golden := filepath.Join("testdata", "search_v2_"+testcase.name+".golden")
+			if *updateGolden {
+				require.NoError(t, os.MkdirAll("testdata", 0755))
+				require.NoError(t, os.WriteFile(golden, actual.Bytes(), 0644))
+			}
+			expected, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			require.Equal(t, string(expected), actual.String())
+		})
+	}
+}
+
+var cursorParam = regexp.MustCompile(`(search_after|search_before)=[^&]+`)
+
+// normalizeGolden replaces values that depend on bleve's scoring so that
+// the golden files only pin the structure of the response.
+func normalizeGolden(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			switch key {
+			case "score":
+				v[key] = 1.0
+			case "next_cursor", "prev_cursor":
+				v[key] = "<cursor>"
+			case "next", "prev":
+				v[key] = cursorParam.ReplaceAllString(item.(string), "$1=<cursor>")
+			default:
+				v[key] = normalizeGolden(item)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeGolden(item)
+		}
+	}
+	return value
+}
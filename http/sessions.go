@@ -0,0 +1,231 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search"
+	"github.com/blevesearch/bleve/v2/search/query"
+	"github.com/julienschmidt/httprouter"
+	"github.com/zerok/pyvideosearch/index"
+)
+
+// sessionFields are the stored fields needed to render a session summary.
+var sessionFields = []string{"title", "url", "speakers.name", "speakers.slug", "thumbnail_url", "collection_title", "collection_slug", "collection_url", "recorded", "recorded_formatted", "tags", "language", "duration"}
+
+type sessionSummary struct {
+	ID                string          `json:"id"`
+	Title             string          `json:"title"`
+	URL               string          `json:"url"`
+	CollectionTitle   string          `json:"collection_title"`
+	CollectionSlug    string          `json:"collection_slug"`
+	CollectionURL     string          `json:"collection_url"`
+	Speakers          []index.Speaker `json:"speakers"`
+	ThumbnailURL      string          `json:"thumbnail_url,omitempty"`
+	Recorded          string          `json:"recorded,omitempty"`
+	RecordedFormatted string          `json:"recorded_formatted,omitempty"`
+	Tags              []string        `json:"tags"`
+	Language          string          `json:"language,omitempty"`
+	Duration          int             `json:"duration,omitempty"`
+}
+
+func newSessionSummary(hit *search.DocumentMatch) sessionSummary {
+	return sessionSummary{
+		ID:                hit.ID,
+		Title:             fieldString(hit.Fields, "title"),
+		URL:               fieldString(hit.Fields, "url"),
+		CollectionTitle:   fieldString(hit.Fields, "collection_title"),
+		CollectionSlug:    fieldString(hit.Fields, "collection_slug"),
+		CollectionURL:     fieldString(hit.Fields, "collection_url"),
+		Speakers:          fieldSpeakers(hit.Fields),
+		ThumbnailURL:      fieldString(hit.Fields, "thumbnail_url"),
+		Recorded:          fieldString(hit.Fields, "recorded"),
+		RecordedFormatted: fieldString(hit.Fields, "recorded_formatted"),
+		Tags:              append(make([]string, 0), fieldStrings(hit.Fields, "tags")...),
+		Language:          fieldString(hit.Fields, "language"),
+		Duration:          int(fieldNumber(hit.Fields, "duration")),
+	}
+}
+
+// sessionDocument is the complete stored representation of a session.
+type sessionDocument struct {
+	sessionSummary
+	Description   string             `json:"description"`
+	Summary       string             `json:"summary,omitempty"`
+	Videos        []index.Video      `json:"videos"`
+	RelatedURLs   []index.RelatedURL `json:"related_urls"`
+	CopyrightText string             `json:"copyright_text,omitempty"`
+	QualityNotes  string             `json:"quality_notes,omitempty"`
+}
+
+func newSessionDocument(hit *search.DocumentMatch) sessionDocument {
+	doc := sessionDocument{
+		sessionSummary: newSessionSummary(hit),
+		Description:    fieldString(hit.Fields, "description"),
+		Summary:        fieldString(hit.Fields, "summary"),
+		Videos:         make([]index.Video, 0, 1),
+		RelatedURLs:    make([]index.RelatedURL, 0),
+		CopyrightText:  fieldString(hit.Fields, "copyright_text"),
+		QualityNotes:   fieldString(hit.Fields, "quality_notes"),
+	}
+	types := fieldStrings(hit.Fields, "videos.type")
+	urls := fieldStrings(hit.Fields, "videos.url")
+	for i, u := range urls {
+		video := index.Video{URL: u}
+		if i < len(types) {
+			video.Type = types[i]
+		}
+		doc.Videos = append(doc.Videos, video)
+	}
+	labels := fieldStrings(hit.Fields, "related_urls.label")
+	for i, u := range fieldStrings(hit.Fields, "related_urls.url") {
+		related := index.RelatedURL{URL: u}
+		if i < len(labels) {
+			related.Label = labels[i]
+		}
+		doc.RelatedURLs = append(doc.RelatedURLs, related)
+	}
+	return doc
+}
+
+// sessionIDFromURL resolves a pyvideo.org URL like /<collection>/<slug>.html
+// to the ID of the session document.
+func sessionIDFromURL(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) != 2 || !strings.HasSuffix(parts[1], ".html") || parts[0] == "" {
+		return "", fmt.Errorf("Not a session URL: %s", raw)
+	}
+	return index.SessionID(parts[0], strings.TrimSuffix(parts[1], ".html")), nil
+}
+
+// loadSession fetches a single session document. It returns nil if there
+// is no session with the given ID.
+func loadSession(idx bleve.Index, id string) (*sessionDocument, error) {
+	req := bleve.NewSearchRequest(bleve.NewDocIDQuery([]string{id}))
+	req.Size = 1
+	req.Fields = append([]string{"description", "summary", "videos.type", "videos.url", "related_urls.label", "related_urls.url", "copyright_text", "quality_notes"}, sessionFields...)
+	res, err := idx.Search(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Hits) == 0 {
+		return nil, nil
+	}
+	doc := newSessionDocument(res.Hits[0])
+	return &doc, nil
+}
+
+func (s *server) handleSession(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	s.writeSession(w, index.SessionID(ps.ByName("collection"), ps.ByName("slug")))
+}
+
+// handleSessionByURL resolves share links by the URL of the session on
+// pyvideo.org.
+func (s *server) handleSessionByURL(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	id, err := sessionIDFromURL(r.FormValue("url"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.writeSession(w, id)
+}
+
+func (s *server) writeSession(w http.ResponseWriter, id string) {
+	s.idxLock.RLock()
+	defer s.idxLock.RUnlock()
+	doc, err := loadSession(s.idx.Index, id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load session")
+		return
+	}
+	if doc == nil {
+		writeError(w, http.StatusNotFound, "Session not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, doc)
+}
+
+// searchSessions returns all sessions matching q ordered by their
+// recording date.
+func searchSessions(idx bleve.Index, q query.Query) ([]sessionSummary, error) {
+	req := bleve.NewSearchRequest(q)
+	req.Size = 0
+	res, err := idx.Search(req)
+	if err != nil {
+		return nil, err
+	}
+	req.Size = int(res.Total)
+	req.Fields = sessionFields
+	req.SortByCustom(search.SortOrder{recordedSort(false), &search.SortDocID{}})
+	res, err = idx.Search(req)
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]sessionSummary, 0, len(res.Hits))
+	for _, hit := range res.Hits {
+		sessions = append(sessions, newSessionSummary(hit))
+	}
+	return sessions, nil
+}
+
+// fieldStrings returns all values of a stored field. Bleve returns single
+// values as-is and multiple values as slice.
+func fieldStrings(fields map[string]interface{}, name string) []string {
+	switch v := fields[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+func fieldString(fields map[string]interface{}, name string) string {
+	values := fieldStrings(fields, name)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// fieldNumber returns the first value of a numeric stored field or 0.
+func fieldNumber(fields map[string]interface{}, name string) float64 {
+	switch v := fields[name].(type) {
+	case float64:
+		return v
+	case []interface{}:
+		if len(v) > 0 {
+			if f, ok := v[0].(float64); ok {
+				return f
+			}
+		}
+	}
+	return 0
+}
+
+func fieldSpeakers(fields map[string]interface{}) []index.Speaker {
+	names := fieldStrings(fields, "speakers.name")
+	slugs := fieldStrings(fields, "speakers.slug")
+	speakers := make([]index.Speaker, 0, len(names))
+	for i, name := range names {
+		speaker := index.Speaker{Name: name}
+		if i < len(slugs) {
+			speaker.Slug = slugs[i]
+		}
+		speakers = append(speakers, speaker)
+	}
+	return speakers
+}
@@ -0,0 +1,46 @@
+package http
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSession(t *testing.T) {
+	srv := newTestServer(t)
+
+	body := doRequest(t, srv, "/api/v1/sessions/pycon-2016/asyncio-basics")
+	require.Equal(t, "session:pycon-2016:asyncio-basics", body["id"])
+	require.Equal(t, "An introduction to asyncio", body["description"])
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"type": "youtube", "url": "https://www.youtube.com/watch?v=asyncio"},
+	}, body["videos"])
+
+	byURL := doRequest(t, srv, "/api/v1/sessions?url=https://pyvideo.org/pycon-2016/asyncio-basics.html")
+	require.Equal(t, body, byURL)
+
+	require.Equal(t, http.StatusNotFound, serve(srv, "/api/v1/sessions/pycon-2016/unknown").Code)
+	require.Equal(t, http.StatusBadRequest, serve(srv, "/api/v1/sessions?url=/speaker/jane-doe").Code)
+}
+
+func TestRelatedSessions(t *testing.T) {
+	srv := newTestServer(t)
+
+	body := doRequest(t, srv, "/api/v1/sessions/pycon-2016/asyncio-basics/related")
+	sessions := body["sessions"].([]interface{})
+	require.Len(t, sessions, 2)
+	// The title match is weighted higher than the shared speaker:
+	require.Equal(t, "session:pycon-2018:advanced-asyncio", sessions[0].(map[string]interface{})["id"])
+	for _, session := range sessions {
+		require.NotEqual(t, "session:pycon-2016:asyncio-basics", session.(map[string]interface{})["id"])
+	}
+
+	body = doRequest(t, srv, "/api/v1/sessions/pycon-2018/advanced-asyncio/related?other_conferences=true")
+	sessions = body["sessions"].([]interface{})
+	require.Len(t, sessions, 1)
+	require.Equal(t, "session:pycon-2016:asyncio-basics", sessions[0].(map[string]interface{})["id"])
+
+	require.Equal(t, http.StatusNotFound, serve(srv, "/api/v1/sessions/pycon-2016/unknown/related").Code)
+	require.Equal(t, http.StatusBadRequest, serve(srv, "/api/v1/sessions/pycon-2016/asyncio-basics/related?size=0").Code)
+}
@@ -0,0 +1,132 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/julienschmidt/httprouter"
+	"github.com/zerok/pyvideosearch/slugify"
+)
+
+type speakerEntry struct {
+	Name      string `json:"name"`
+	Slug      string `json:"slug"`
+	TalkCount int    `json:"talk_count"`
+}
+
+type speakerListResponse struct {
+	Speakers []speakerEntry `json:"speakers"`
+	Paging   paging         `json:"paging"`
+}
+
+type collectionRef struct {
+	Title string `json:"title"`
+	Slug  string `json:"slug"`
+	URL   string `json:"url"`
+}
+
+type speakerResponse struct {
+	speakerEntry
+	Conferences []collectionRef  `json:"conferences"`
+	Sessions    []sessionSummary `json:"sessions"`
+}
+
+// handleSpeakers lists all speakers ordered by their slug. The optional
+// prefix parameter restricts the list to speakers whose slug starts with
+// the slugified prefix.
+func (s *server) handleSpeakers(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	page, size, err := parsePageAndSize(r, s.opts.MaxPageSize)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	prefix := slugify.Slugify(r.FormValue("prefix"))
+
+	s.idxLock.RLock()
+	defer s.idxLock.RUnlock()
+	terms, err := fieldTerms(s.idx.Index, "speakers.slug", prefix)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to list speakers")
+		return
+	}
+	resp := speakerListResponse{
+		Speakers: make([]speakerEntry, 0, size),
+		Paging:   newListPaging(r, page, size, len(terms)),
+	}
+	for _, term := range pageOf(terms, page, size) {
+		name, err := speakerName(s.idx.Index, term.Term)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to list speakers")
+			return
+		}
+		resp.Speakers = append(resp.Speakers, speakerEntry{
+			Name:      name,
+			Slug:      term.Term,
+			TalkCount: int(term.Count),
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *server) handleSpeaker(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	slug := ps.ByName("slug")
+	q := bleve.NewTermQuery(slug)
+	q.SetField("speakers.slug")
+
+	s.idxLock.RLock()
+	defer s.idxLock.RUnlock()
+	sessions, err := searchSessions(s.idx.Index, q)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load speaker")
+		return
+	}
+	if len(sessions) == 0 {
+		writeError(w, http.StatusNotFound, "Speaker not found")
+		return
+	}
+	resp := speakerResponse{
+		speakerEntry: speakerEntry{
+			Slug:      slug,
+			TalkCount: len(sessions),
+		},
+		Conferences: make([]collectionRef, 0, 1),
+		Sessions:    sessions,
+	}
+	seen := make(map[string]struct{})
+	for _, session := range sessions {
+		for _, speaker := range session.Speakers {
+			if speaker.Slug == slug && resp.Name == "" {
+				resp.Name = speaker.Name
+			}
+		}
+		if _, ok := seen[session.CollectionSlug]; ok {
+			continue
+		}
+		seen[session.CollectionSlug] = struct{}{}
+		resp.Conferences = append(resp.Conferences, collectionRef{
+			Title: session.CollectionTitle,
+			Slug:  session.CollectionSlug,
+			URL:   session.CollectionURL,
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// speakerName looks up the display name of the speaker with the given slug.
+func speakerName(idx bleve.Index, slug string) (string, error) {
+	q := bleve.NewTermQuery(slug)
+	q.SetField("speakers.slug")
+	req := bleve.NewSearchRequest(q)
+	req.Size = 1
+	req.Fields = []string{"speakers.name", "speakers.slug"}
+	res, err := idx.Search(req)
+	if err != nil || len(res.Hits) == 0 {
+		return "", err
+	}
+	for _, speaker := range fieldSpeakers(res.Hits[0].Fields) {
+		if speaker.Slug == slug {
+			return speaker.Name, nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,59 @@
+package http
+
+import (
+	"net/http"
+	"testing"
+
+	bleveindex "github.com/blevesearch/bleve_index_api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpeakers(t *testing.T) {
+	srv := newTestServer(t)
+
+	body := doRequest(t, srv, "/api/v1/speakers")
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"name": "Jane Doe", "slug": "jane-doe", "talk_count": float64(2)},
+		map[string]interface{}{"name": "John Smith", "slug": "john-smith", "talk_count": float64(1)},
+	}, body["speakers"])
+
+	body = doRequest(t, srv, "/api/v1/speakers?prefix=Jo")
+	require.Len(t, body["speakers"], 1)
+
+	body = doRequest(t, srv, "/api/v1/speakers?size=1")
+	require.Len(t, body["speakers"], 1)
+	require.Equal(t, float64(2), pagingOf(body)["total_hits"])
+	body = doRequest(t, srv, pagingOf(body)["next"].(string))
+	require.Equal(t, "john-smith", body["speakers"].([]interface{})[0].(map[string]interface{})["slug"])
+}
+
+func TestSpeakersInvalidPage(t *testing.T) {
+	srv := newTestServer(t)
+	for _, query := range []string{"page=0", "page=4611686018427387904&size=4", "size=0"} {
+		rec := serve(srv, "/api/v1/speakers?"+query)
+		require.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+
+	body := doRequest(t, srv, "/api/v1/speakers?page=3&size=1")
+	require.Empty(t, body["speakers"])
+	require.Empty(t, pageOf(make([]bleveindex.DictEntry, 3), 4611686018427387904, 4))
+}
+
+func TestSpeaker(t *testing.T) {
+	srv := newTestServer(t)
+
+	body := doRequest(t, srv, "/api/v1/speakers/jane-doe")
+	require.Equal(t, "Jane Doe", body["name"])
+	require.Equal(t, float64(2), body["talk_count"])
+	require.Len(t, body["conferences"], 2)
+	sessions := body["sessions"].([]interface{})
+	require.Len(t, sessions, 2)
+	// Sessions are ordered by date with undated ones at the end:
+	require.Equal(t, "session:pycon-2016:asyncio-basics", sessions[0].(map[string]interface{})["id"])
+}
+
+func TestUnknownSpeaker(t *testing.T) {
+	srv := newTestServer(t)
+	rec := serve(srv, "/api/v1/speakers/nobody")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,206 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+	"github.com/julienschmidt/httprouter"
+	"github.com/zerok/pyvideosearch/index"
+	"github.com/zerok/pyvideosearch/slugify"
+)
+
+const defaultSuggestSize = 5
+const maxSuggestSize = 20
+
+// speakerSuggestCandidates is the number of speaker names fetched before
+// filtering out co-speakers that don't actually match the input.
+const speakerSuggestCandidates = 50
+
+type titleSuggestion struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+type speakerSuggestion struct {
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+type collectionSuggestion struct {
+	Title string `json:"title"`
+	Slug  string `json:"slug"`
+}
+
+type suggestResponse struct {
+	Query       string                 `json:"query"`
+	Titles      []titleSuggestion      `json:"titles"`
+	Speakers    []speakerSuggestion    `json:"speakers"`
+	Collections []collectionSuggestion `json:"collections"`
+}
+
+// handleSuggest returns completions for the given input grouped by session
+// titles, speakers and collections. Every word of the input has to be the
+// prefix of a word in the suggestion.
+func (s *server) handleSuggest(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	size := defaultSuggestSize
+	if v := r.FormValue("size"); v != "" {
+		var err error
+		size, err = strconv.Atoi(v)
+		if err != nil || size < 1 || size > maxSuggestSize {
+			writeError(w, http.StatusBadRequest, "Invalid size: "+v)
+			return
+		}
+	}
+	input := strings.TrimSpace(r.FormValue("q"))
+	resp := suggestResponse{
+		Query:       input,
+		Titles:      make([]titleSuggestion, 0, size),
+		Speakers:    make([]speakerSuggestion, 0, size),
+		Collections: make([]collectionSuggestion, 0, size),
+	}
+	if input == "" {
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	s.idxLock.RLock()
+	defer s.idxLock.RUnlock()
+	var err error
+	if resp.Titles, err = suggestTitles(s.idx.Index, input, size); err != nil {
+		writeError(w, http.StatusInternalServerError, "Query failed")
+		return
+	}
+	if resp.Speakers, err = suggestSpeakers(s.idx.Index, input, size); err != nil {
+		writeError(w, http.StatusInternalServerError, "Query failed")
+		return
+	}
+	if resp.Collections, err = suggestCollections(s.idx.Index, input, size); err != nil {
+		writeError(w, http.StatusInternalServerError, "Query failed")
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func suggestQuery(input string, field string) query.Query {
+	q := bleve.NewMatchQuery(input)
+	q.SetField(field)
+	q.Analyzer = index.SuggestQueryAnalyzer
+	q.SetOperator(query.MatchQueryOperatorAnd)
+	return q
+}
+
+func suggestTitles(idx bleve.Index, input string, size int) ([]titleSuggestion, error) {
+	req := bleve.NewSearchRequest(suggestQuery(input, "title_suggest"))
+	// Fetch some more hits as the same title is often used more than once.
+	req.Size = size * 2
+	req.Fields = []string{"title", "url"}
+	res, err := idx.Search(req)
+	if err != nil {
+		return nil, err
+	}
+	suggestions := make([]titleSuggestion, 0, size)
+	seen := make(map[string]struct{})
+	for _, hit := range res.Hits {
+		title := fieldString(hit.Fields, "title")
+		if _, ok := seen[title]; ok {
+			continue
+		}
+		seen[title] = struct{}{}
+		suggestions = append(suggestions, titleSuggestion{
+			ID:    hit.ID,
+			Title: title,
+			URL:   fieldString(hit.Fields, "url"),
+		})
+		if len(suggestions) == size {
+			break
+		}
+	}
+	return suggestions, nil
+}
+
+// suggestSpeakers uses a facet to get the most prolific speakers of all
+// matching sessions. As these also contain co-speakers, the names have to
+// be checked against the input again.
+func suggestSpeakers(idx bleve.Index, input string, size int) ([]speakerSuggestion, error) {
+	req := bleve.NewSearchRequest(suggestQuery(input, "speakers.name_suggest"))
+	req.Size = 0
+	req.AddFacet("speakers", bleve.NewFacetRequest("speakers.name", speakerSuggestCandidates))
+	res, err := idx.Search(req)
+	if err != nil {
+		return nil, err
+	}
+	suggestions := make([]speakerSuggestion, 0, size)
+	for _, term := range res.Facets["speakers"].Terms.Terms() {
+		if !matchesPrefixes(term.Term, input) {
+			continue
+		}
+		suggestions = append(suggestions, speakerSuggestion{
+			Name: term.Term,
+			Slug: slugify.Slugify(term.Term),
+		})
+		if len(suggestions) == size {
+			break
+		}
+	}
+	return suggestions, nil
+}
+
+func suggestCollections(idx bleve.Index, input string, size int) ([]collectionSuggestion, error) {
+	req := bleve.NewSearchRequest(suggestQuery(input, "collection_title_suggest"))
+	req.Size = 0
+	req.AddFacet("collections", bleve.NewFacetRequest("collection_title", size))
+	res, err := idx.Search(req)
+	if err != nil {
+		return nil, err
+	}
+	suggestions := make([]collectionSuggestion, 0, size)
+	for _, term := range res.Facets["collections"].Terms.Terms() {
+		slug, err := collectionSlug(idx, term.Term)
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, collectionSuggestion{
+			Title: term.Term,
+			Slug:  slug,
+		})
+	}
+	return suggestions, nil
+}
+
+// collectionSlug looks up the slug of a collection as it isn't necessarily
+// derived from its title.
+func collectionSlug(idx bleve.Index, title string) (string, error) {
+	q := bleve.NewTermQuery(title)
+	q.SetField("collection_title")
+	req := bleve.NewSearchRequest(q)
+	req.Size = 1
+	req.Fields = []string{"collection_slug"}
+	res, err := idx.Search(req)
+	if err != nil || len(res.Hits) == 0 {
+		return "", err
+	}
+	return fieldString(res.Hits[0].Fields, "collection_slug"), nil
+}
+
+// matchesPrefixes checks if every word of input is the prefix of a word in
+// value.
+func matchesPrefixes(value string, input string) bool {
+	words := strings.Fields(strings.ToLower(value))
+	for _, prefix := range strings.Fields(strings.ToLower(input)) {
+		found := false
+		for _, word := range words {
+			if strings.HasPrefix(word, prefix) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
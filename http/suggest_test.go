@@ -0,0 +1,39 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuggest(t *testing.T) {
+	srv := newTestServer(t)
+
+	body := doRequest(t, srv, "/api/v1/suggest?q=asy")
+	titles := []string{}
+	for _, title := range body["titles"].([]interface{}) {
+		titles = append(titles, title.(map[string]interface{})["title"].(string))
+	}
+	require.ElementsMatch(t, []string{"Asyncio basics", "Advanced asyncio"}, titles)
+	require.Empty(t, body["speakers"])
+	require.Empty(t, body["collections"])
+
+	body = doRequest(t, srv, "/api/v1/suggest?q=Ja")
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"name": "Jane Doe", "slug": "jane-doe"},
+	}, body["speakers"])
+
+	body = doRequest(t, srv, "/api/v1/suggest?q=pycon+201")
+	require.Len(t, body["collections"], 2)
+	require.Empty(t, body["titles"])
+
+	body = doRequest(t, srv, "/api/v1/suggest?q=adv+asy&size=1")
+	require.Len(t, body["titles"], 1)
+}
+
+func TestMatchesPrefixes(t *testing.T) {
+	require.True(t, matchesPrefixes("Jane Doe", "ja"))
+	require.True(t, matchesPrefixes("Jane Doe", "do JA"))
+	require.False(t, matchesPrefixes("John Smith", "ja"))
+	require.False(t, matchesPrefixes("Jane Doe", "jane x"))
+}
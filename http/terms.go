@@ -0,0 +1,48 @@
+package http
+
+import (
+	"github.com/blevesearch/bleve/v2"
+	bleveindex "github.com/blevesearch/bleve_index_api"
+)
+
+// fieldTerms returns all terms of a keyword field starting with prefix
+// together with the number of documents containing them.
+func fieldTerms(idx bleve.Index, field string, prefix string) ([]bleveindex.DictEntry, error) {
+	var dict bleveindex.FieldDict
+	var err error
+	// An empty prefix doesn't match anything in bleve.
+	if prefix == "" {
+		dict, err = idx.FieldDict(field)
+	} else {
+		dict, err = idx.FieldDictPrefix(field, []byte(prefix))
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer dict.Close()
+	terms := make([]bleveindex.DictEntry, 0, 100)
+	for {
+		entry, err := dict.Next()
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			break
+		}
+		terms = append(terms, *entry)
+	}
+	return terms, nil
+}
+
+// pageOf returns the items of the 1-based page.
+func pageOf(terms []bleveindex.DictEntry, page int, size int) []bleveindex.DictEntry {
+	from := (page - 1) * size
+	if from < 0 || from >= len(terms) {
+		return nil
+	}
+	to := from + size
+	if to > len(terms) {
+		to = len(terms)
+	}
+	return terms[from:to]
+}
@@ -2,7 +2,9 @@ package index
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"path"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -16,21 +18,56 @@ const outputTimestampFormat = "Mon Jan 2 2006"
 type State struct {
 	Ref   string
 	Index string
+	// MappingVersion is the version of the mapping the index was built
+	// with. Indices with an outdated mapping are rebuilt from scratch.
+	MappingVersion int
+	// RefMode and RefName describe how the data the index was built from
+	// was selected, e.g. the branch or the tag.
+	RefMode string `json:",omitempty"`
+	RefName string `json:",omitempty"`
+	// Problems lists the broken data files that were skipped.
+	Problems []Problem `json:",omitempty"`
 }
 
 type Video struct {
-	Type string
-	URL  string
+	Type string `json:"type"`
+	URL  string `json:"url"`
 }
 
 type Session struct {
-	Title        string
-	Description  string
-	Speakers     []string
-	Recorded     string
-	Videos       []Video
-	Slug         string
-	ThumbnailURL string `json:"thumbnail_url"`
+	Title         string
+	Description   string
+	Speakers      []string
+	Recorded      string
+	Videos        []Video
+	Slug          string
+	ThumbnailURL  string `json:"thumbnail_url"`
+	Tags          []string
+	Language      string
+	Duration      float64
+	RelatedURLs   []RelatedURL `json:"related_urls"`
+	CopyrightText string       `json:"copyright_text"`
+	Summary       string
+	QualityNotes  string `json:"quality_notes"`
+	// File is the name of the session's file within the videos folder.
+	File string `json:"-"`
+}
+
+// RelatedURL links to slides, repositories etc. of a session. Older data
+// files only contain the plain URL instead of an object.
+type RelatedURL struct {
+	Label string `json:"label"`
+	URL   string `json:"url"`
+}
+
+func (u *RelatedURL) UnmarshalJSON(data []byte) error {
+	var plain string
+	if err := json.Unmarshal(data, &plain); err == nil {
+		u.URL = plain
+		return nil
+	}
+	type relatedURL RelatedURL
+	return json.Unmarshal(data, (*relatedURL)(u))
 }
 
 type Speaker struct {
@@ -42,18 +79,52 @@ type Collection struct {
 	Title    string `json:"title"`
 	Slug     string
 	Sessions []Session
+	// Folder is the name of the collection's folder within the data folder.
+	Folder string `json:"-"`
 }
 
 type IndexedSession struct {
-	Title             string    `json:"title"`
-	Description       string    `json:"description"`
-	URL               string    `json:"url"`
-	CollectionTitle   string    `json:"collection_title"`
-	CollectionURL     string    `json:"collection_url"`
-	Speakers          []Speaker `json:"speakers"`
-	ThumbnailURL      string    `json:"thumbnail_url"`
-	Recorded          time.Time `json:"recorded"`
-	RecordedFormatted string    `json:"recorded_formatted"`
+	Title             string       `json:"title"`
+	Description       string       `json:"description"`
+	URL               string       `json:"url"`
+	CollectionTitle   string       `json:"collection_title"`
+	CollectionSlug    string       `json:"collection_slug"`
+	CollectionURL     string       `json:"collection_url"`
+	Speakers          []Speaker    `json:"speakers"`
+	ThumbnailURL      string       `json:"thumbnail_url"`
+	Recorded          time.Time    `json:"recorded"`
+	RecordedFormatted string       `json:"recorded_formatted"`
+	HasVideo          bool         `json:"has_video"`
+	Videos            []Video      `json:"videos"`
+	Tags              []string     `json:"tags"`
+	Language          string       `json:"language"`
+	Duration          int          `json:"duration"`
+	RelatedURLs       []RelatedURL `json:"related_urls"`
+	CopyrightText     string       `json:"copyright_text"`
+	Summary           string       `json:"summary"`
+	QualityNotes      string       `json:"quality_notes"`
+	// SourcePath is the path of the session's file relative to the data
+	// folder and CollectionFolder the folder of its collection. Both are
+	// used to find the documents affected by changed files.
+	SourcePath       string `json:"source_path"`
+	CollectionFolder string `json:"collection_folder"`
+	// AnalysisLanguage selects the document mapping with the matching
+	// language-specific fields. It is empty if the language is unknown.
+	AnalysisLanguage string `json:"-"`
+}
+
+// BleveType returns the name of the document mapping used for the session.
+func (s IndexedSession) BleveType() string {
+	if s.AnalysisLanguage == "" {
+		return sessionType
+	}
+	return sessionType + "_" + s.AnalysisLanguage
+}
+
+// SessionID returns the ID of the document representing the session with
+// the given slug within a collection.
+func SessionID(collectionSlug string, sessionSlug string) string {
+	return fmt.Sprintf("session:%s:%s", collectionSlug, sessionSlug)
 }
 
 func (s IndexedSession) Type() string {
@@ -77,9 +148,24 @@ func newIndexedSession(ctx context.Context, session *Session, collection *Collec
 		Speakers:        speakers,
 		URL:             fmt.Sprintf("/%s/%s.html", collection.Slug, session.Slug),
 		CollectionTitle: collection.Title,
+		CollectionSlug:  collection.Slug,
 		CollectionURL:   fmt.Sprintf("/events/%s.html", collection.Slug),
 		ThumbnailURL:    session.ThumbnailURL,
+		HasVideo:        len(session.Videos) > 0,
+		Videos:          session.Videos,
+		Tags:            session.Tags,
+		Language:        session.Language,
+		Duration:        int(session.Duration),
+		RelatedURLs:     session.RelatedURLs,
+		CopyrightText:   session.CopyrightText,
+		Summary:         session.Summary,
+		QualityNotes:    session.QualityNotes,
+	}
+	if collection.Folder != "" && session.File != "" {
+		res.SourcePath = path.Join(collection.Folder, videosFolder, session.File)
+		res.CollectionFolder = collection.Folder
 	}
+	res.AnalysisLanguage = analysisLanguage(session.Language, session.Title+"\n"+session.Description)
 
 	if session.Recorded != "" {
 		valid := false
@@ -3,30 +3,94 @@ package index
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 	uuid "github.com/satori/go.uuid"
 	"github.com/zerok/pyvideosearch/slugify"
+	"github.com/zerok/pyvideosearch/source"
 )
 
 type Index struct {
 	Index bleve.Index
 	Path  string
+
+	rangeLock     sync.Mutex
+	rangeValid    bool
+	recordedRange [2]time.Time
+
+	problemsLock sync.Mutex
+	problems     []Problem
 }
 
 func (i *Index) Close() error {
 	return i.Index.Close()
 }
 
+// RecordedRange returns the recording dates of the oldest and the newest
+// session within the index. Both are zero if no session has a recording
+// date. The result is cached until the index is updated.
+func (i *Index) RecordedRange() (time.Time, time.Time, error) {
+	i.rangeLock.Lock()
+	defer i.rangeLock.Unlock()
+	if i.rangeValid {
+		return i.recordedRange[0], i.recordedRange[1], nil
+	}
+	var recordedRange [2]time.Time
+	for pos, desc := range []bool{false, true} {
+		req := bleve.NewSearchRequest(bleve.NewMatchAllQuery())
+		req.Size = 1
+		req.SortByCustom(search.SortOrder{&search.SortField{
+			Field:   "recorded",
+			Type:    search.SortFieldAsDate,
+			Missing: search.SortFieldMissingLast,
+			Desc:    desc,
+		}})
+		req.Fields = []string{"recorded"}
+		res, err := i.Index.Search(req)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		if len(res.Hits) == 0 {
+			break
+		}
+		if value, ok := res.Hits[0].Fields["recorded"].(string); ok {
+			recordedRange[pos], _ = time.Parse(time.RFC3339, value)
+		}
+	}
+	i.recordedRange = recordedRange
+	i.rangeValid = true
+	return recordedRange[0], recordedRange[1], nil
+}
+
+// resetCaches has to be called after the documents of the index changed.
+func (i *Index) resetCaches() {
+	i.rangeLock.Lock()
+	defer i.rangeLock.Unlock()
+	i.rangeValid = false
+}
+
+// Problems returns the data files that were skipped while building and
+// updating the index because they are broken.
+func (i *Index) Problems() []Problem {
+	i.problemsLock.Lock()
+	defer i.problemsLock.Unlock()
+	return append([]Problem{}, i.problems...)
+}
+
+func (i *Index) setProblems(problems []Problem) {
+	i.problemsLock.Lock()
+	defer i.problemsLock.Unlock()
+	i.problems = problems
+}
+
 func (i *Index) Destroy() error {
 	if i.Path != "" {
 		return os.RemoveAll(i.Path)
@@ -38,7 +102,11 @@ const categoryFile = "category.json"
 const videosFolder = "videos"
 const stateFile = ".state"
 
-func WatchForUpdates(ctx context.Context, idxChan chan *Index, indexPath string, dataPath string, interval time.Duration, deleteOldIndex bool) error {
+// WatchForUpdates updates the data source in the given interval and
+// whenever trigger requests it. Both are optional. If the ref of the source
+// changed, the index is synchronized with it and sent to idxChan. Unless
+// strict is set, broken data files are skipped and reported.
+func WatchForUpdates(ctx context.Context, idxChan chan *Index, idx *Index, indexPath string, src source.Source, interval time.Duration, trigger *Trigger, strict bool, deleteOldIndex bool) error {
 	logger := zerolog.Ctx(ctx)
 	for {
 		select {
@@ -49,45 +117,78 @@ func WatchForUpdates(ctx context.Context, idxChan chan *Index, indexPath string,
 
 		logger.Info().Msg("Checking upstream for new commits")
 
-		if err := updateRepo(ctx, dataPath); err != nil {
-			return errors.Wrapf(err, "Failed to update git repository at %s", dataPath)
+		if err := src.Update(ctx); err != nil {
+			return errors.Wrapf(err, "Failed to update data at %s", src.Path())
 		}
 
-		ref, err := getRepoState(ctx, dataPath)
+		newIdx, changed, err := syncIndex(ctx, idx, indexPath, src, strict, deleteOldIndex)
 		if err != nil {
-			return errors.Wrapf(err, "Failed to get data repo state of %s", dataPath)
+			return err
+		}
+		if changed {
+			idx = newIdx
+			idxChan <- idx
 		}
 
-		idxRef, err := getIndexState(ctx, indexPath)
-		if err != nil {
-			return errors.Wrapf(err, "Failed to get index state of %s", indexPath)
+		if !waitForUpdate(ctx, interval, trigger) {
+			return nil
 		}
+	}
+}
+
+// syncIndex brings idx up to date with the current ref of src. Only the
+// documents of changed files are updated in idx. If that isn't possible,
+// e.g. because the mapping changed, a new index is built and returned
+// instead. The boolean result reports whether the index changed.
+func syncIndex(ctx context.Context, idx *Index, indexPath string, src source.Source, strict bool, deleteOldIndex bool) (*Index, bool, error) {
+	logger := zerolog.Ctx(ctx)
+	ref, err := src.Ref(ctx)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "Failed to get data state of %s", src.Path())
+	}
 
-		logger.Info().Str("index", idxRef.Ref).Str("repo", ref).Msg("Comparing states")
+	idxRef, err := getIndexState(ctx, indexPath)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "Failed to get index state of %s", indexPath)
+	}
 
-		oldIdx, err := findIndex(indexPath)
-		if err != nil {
-			return errors.Wrapf(err, "Failed to find old index")
-		}
+	logger.Info().Str("index", idxRef.Ref).Str("repo", ref.ID).Str("mode", ref.Mode).Str("name", ref.Name).Msg("Comparing states")
 
-		if idxRef.Ref != ref {
-			logger.Info().Msg("New commits found. Will rebuild index")
-			newIdxName := newIndexName(indexPath)
-			idx, err := createNewIndex(ctx, filepath.Join(indexPath, newIdxName), dataPath)
-			if err != nil {
-				return errors.Wrap(err, "Failed to load the new index")
-			}
-			if err := setIndexState(ctx, indexPath, &State{Index: newIdxName, Ref: ref}); err != nil {
-				return err
-			}
-			if oldIdx != "" && deleteOldIndex {
-				os.RemoveAll(oldIdx)
+	if idxRef.Ref == ref.ID {
+		if idxRef.RefMode != ref.Mode || idxRef.RefName != ref.Name {
+			idxRef.RefMode, idxRef.RefName = ref.Mode, ref.Name
+			if err := setIndexState(ctx, indexPath, idxRef); err != nil {
+				return nil, false, err
 			}
-			idxChan <- idx
 		}
+		return idx, false, nil
+	}
+
+	err = updateIndexInPlace(ctx, idx, src, idxRef, ref.ID, strict)
+	if err == nil {
+		if err := setIndexState(ctx, indexPath, newState(idxRef.Index, ref, idx.Problems())); err != nil {
+			return nil, false, err
+		}
+		return idx, true, nil
+	}
 
-		time.Sleep(interval)
+	logger.Info().Err(err).Msg("New commits found but the index can't be updated in place. Will rebuild index")
+	oldIdx, err := findIndex(indexPath)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "Failed to find old index")
+	}
+	newIdxName := newIndexName(indexPath)
+	newIdx, err := createNewIndex(ctx, filepath.Join(indexPath, newIdxName), src.Path(), strict)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "Failed to load the new index")
+	}
+	if err := setIndexState(ctx, indexPath, newState(newIdxName, ref, newIdx.Problems())); err != nil {
+		return nil, false, err
 	}
+	if oldIdx != "" && deleteOldIndex {
+		os.RemoveAll(oldIdx)
+	}
+	return newIdx, true, nil
 }
 
 func readDir(path string) ([]os.FileInfo, error) {
@@ -125,34 +226,41 @@ func newIndexName(root string) string {
 	return uuid.NewV4().String()
 }
 
-func createNewIndex(ctx context.Context, indexPath string, dataPath string) (*Index, error) {
-	sessionIndexMapping := bleve.NewDocumentMapping()
-	sessionIndexMapping.AddFieldMappingsAt("title", bleve.NewTextFieldMapping())
-	sessionIndexMapping.AddFieldMappingsAt("description", bleve.NewTextFieldMapping())
-
-	mapping := bleve.NewIndexMapping()
-	mapping.AddDocumentMapping("session", sessionIndexMapping)
-	idx, err := bleve.New(indexPath, mapping)
+func createNewIndex(ctx context.Context, indexPath string, dataPath string, strict bool) (*Index, error) {
+	idx, err := bleve.New(indexPath, NewMapping())
 	if err != nil {
 		return nil, errors.Wrapf(err, "Failed to create new index in %s", indexPath)
 	}
-	if err := fillIndex(ctx, idx, dataPath); err != nil {
+	r := newReporter(dataPath, strict)
+	if err := fillIndex(ctx, idx, dataPath, r); err != nil {
 		return nil, errors.Wrapf(err, "Failed to build index at %s", indexPath)
 	}
 	return &Index{
-		Index: idx,
-		Path:  indexPath,
+		Index:    idx,
+		Path:     indexPath,
+		problems: r.report(),
 	}, nil
 }
 
 // LoadIndex attempts to load an index from a given path or build it based
 // on the data folder. If the index already exists then you can enforce a
-// rebuild using the forceRebuild parameter.
-func LoadIndex(ctx context.Context, indexPath string, dataFolder string, forceRebuild bool, deleteOld bool) (*Index, error) {
+// rebuild using the forceRebuild parameter. If strict isn't set, broken
+// data files are skipped and reported instead of failing the build.
+// Sources implementing source.Preparer are prepared first and an existing
+// index is synchronized with them.
+func LoadIndex(ctx context.Context, indexPath string, src source.Source, forceRebuild bool, strict bool, deleteOld bool) (*Index, error) {
 	logger := zerolog.Ctx(ctx)
 	logger.Info().Msg("Loading index")
 	defer logger.Info().Msg("Load complete")
 	var create bool
+	var state *State
+
+	preparer, prepare := src.(source.Preparer)
+	if prepare {
+		if err := preparer.Prepare(ctx); err != nil {
+			return nil, errors.Wrapf(err, "Failed to prepare data at %s", src.Path())
+		}
+	}
 
 	idxPath, err := findIndex(indexPath)
 	if err != nil {
@@ -161,6 +269,9 @@ func LoadIndex(ctx context.Context, indexPath string, dataFolder string, forceRe
 	if idxPath == "" {
 		create = true
 		logger.Info().Msgf("%s doesn't exist yet. Creating a new index.", indexPath)
+	} else if state, err = getIndexState(ctx, indexPath); err != nil || state.MappingVersion != MappingVersion {
+		forceRebuild = true
+		logger.Info().Msgf("%s was built with an outdated mapping. Rebuilding the index.", idxPath)
 	}
 
 	if forceRebuild || create {
@@ -174,47 +285,55 @@ func LoadIndex(ctx context.Context, indexPath string, dataFolder string, forceRe
 		}
 		idxName := newIndexName(indexPath)
 		idxPath = filepath.Join(indexPath, idxName)
-		idx, err := createNewIndex(ctx, idxPath, dataFolder)
+		idx, err := createNewIndex(ctx, idxPath, src.Path(), strict)
 		if err != nil {
 			return nil, errors.Wrapf(err, "Failed to create new index in %s", indexPath)
 		}
-		ref, err := getRepoState(ctx, dataFolder)
+		ref, err := src.Ref(ctx)
 		if err != nil {
 			return nil, err
 		}
-		if err := setIndexState(ctx, indexPath, &State{Index: idxName, Ref: ref}); err != nil {
+		if err := setIndexState(ctx, indexPath, newState(idxName, ref, idx.Problems())); err != nil {
 			return nil, err
 		}
 		return idx, err
 	}
 	logger.Info().Msgf("%s already exists. Loading index from there.", idxPath)
-	idx, err := bleve.Open(idxPath)
+	i, err := bleve.Open(idxPath)
 	if err != nil {
 		return nil, err
 	}
-	return &Index{
-		Index: idx,
-		Path:  idxPath,
-	}, err
-}
-
-func parseCollection(ctx context.Context, p string) (Collection, error) {
-	result := Collection{}
-	categoryPath := filepath.Join(p, categoryFile)
-	videosPath := filepath.Join(p, videosFolder)
-	fp, err := os.Open(categoryPath)
-	if err != nil {
-		return result, errors.Wrapf(err, "Failed to open category.json of %s", p)
+	idx := &Index{
+		Index:    i,
+		Path:     idxPath,
+		problems: state.Problems,
 	}
-	if err := json.NewDecoder(fp).Decode(&result); err != nil {
-		fp.Close()
-		return result, errors.Wrapf(err, "Failed to decode %s", categoryPath)
+	if prepare {
+		newIdx, changed, err := syncIndex(ctx, idx, indexPath, src, strict, deleteOld)
+		if err != nil {
+			idx.Close()
+			return nil, err
+		}
+		if changed && newIdx != idx {
+			idx.Close()
+		}
+		return newIdx, nil
 	}
-	fp.Close()
+	return idx, nil
+}
 
-	if result.Slug == "" {
-		result.Slug = slugify.Slugify(result.Title)
+// parseCollection parses the collection in folder p with all its sessions.
+// Broken files are skipped if r allows it. If the category.json itself is
+// broken, errSkipped is returned.
+func parseCollection(ctx context.Context, p string, r *reporter) (Collection, error) {
+	result, err := parseCategory(p)
+	if err != nil {
+		if r.skip(ctx, filepath.Join(p, categoryFile), err) {
+			return result, errSkipped
+		}
+		return result, err
 	}
+	videosPath := filepath.Join(p, videosFolder)
 
 	videoFiles, err := readDir(videosPath)
 	if err != nil {
@@ -233,6 +352,9 @@ func parseCollection(ctx context.Context, p string) (Collection, error) {
 		}
 		session, err := parseSession(videoPath)
 		if err != nil {
+			if r.skip(ctx, videoPath, err) {
+				continue
+			}
 			return result, errors.Wrapf(err, "Failed to parse session file %s", videoPath)
 		}
 		result.Sessions = append(result.Sessions, session)
@@ -241,23 +363,40 @@ func parseCollection(ctx context.Context, p string) (Collection, error) {
 	return result, nil
 }
 
+// parseCategory parses the category.json of the collection in folder p
+// without any of its sessions.
+func parseCategory(p string) (Collection, error) {
+	result := Collection{}
+	categoryPath := filepath.Join(p, categoryFile)
+	if err := decodeJSONFile(categoryPath, &result); err != nil {
+		if os.IsNotExist(err) {
+			return result, errors.Wrapf(err, "Failed to open category.json of %s", p)
+		}
+		return result, errors.Wrapf(err, "Failed to decode %s", categoryPath)
+	}
+	if result.Slug == "" {
+		result.Slug = slugify.Slugify(result.Title)
+	}
+	result.Folder = filepath.Base(p)
+	return result, nil
+}
+
 func parseSession(p string) (Session, error) {
 	result := Session{}
-	fp, err := os.Open(p)
-	if err != nil {
-		return result, errors.Wrapf(err, "Failed to open session file %s", p)
-	}
-	defer fp.Close()
-	if err := json.NewDecoder(fp).Decode(&result); err != nil {
+	if err := decodeJSONFile(p, &result); err != nil {
+		if os.IsNotExist(err) {
+			return result, errors.Wrapf(err, "Failed to open session file %s", p)
+		}
 		return result, errors.Wrapf(err, "Failed to parse session file %s", p)
 	}
 	if result.Slug == "" {
 		result.Slug = slugify.Slugify(strings.TrimSpace(result.Title))
 	}
+	result.File = filepath.Base(p)
 	return result, nil
 }
 
-func runCollectionParser(ctx context.Context, wait *sync.WaitGroup, errs chan error, parsedCollections chan Collection, work <-chan string) {
+func runCollectionParser(ctx context.Context, wait *sync.WaitGroup, errs chan error, parsedCollections chan Collection, work <-chan string, r *reporter) {
 	logger := zerolog.Ctx(ctx)
 	defer wait.Done()
 	defer logger.Info().Msg("Parser done")
@@ -269,9 +408,15 @@ func runCollectionParser(ctx context.Context, wait *sync.WaitGroup, errs chan er
 			if !ok {
 				return
 			}
-			coll, err := parseCollection(ctx, w)
+			coll, err := parseCollection(ctx, w, r)
+			if err == errSkipped {
+				continue
+			}
 			if err != nil {
-				errs <- err
+				select {
+				case errs <- err:
+				case <-ctx.Done():
+				}
 				return
 			}
 			parsedCollections <- coll
@@ -317,7 +462,7 @@ func runIndexer(ctx context.Context, wait *sync.WaitGroup, errs chan error, idx
 			logger.Info().Msgf("Indexing %s", collection.Title)
 			batch := idx.NewBatch()
 			for _, session := range collection.Sessions {
-				id := fmt.Sprintf("session:%s:%s", collection.Slug, session.Slug)
+				id := SessionID(collection.Slug, session.Slug)
 				batch.Index(id, newIndexedSession(ctx, &session, &collection))
 			}
 			idx.Batch(batch)
@@ -325,7 +470,9 @@ func runIndexer(ctx context.Context, wait *sync.WaitGroup, errs chan error, idx
 	}
 }
 
-func fillIndex(ctx context.Context, idx bleve.Index, dataFolder string) error {
+// fillIndex indexes all collections within dataFolder. Broken files are
+// recorded in r and skipped unless r is nil.
+func fillIndex(ctx context.Context, idx bleve.Index, dataFolder string, r *reporter) error {
 	logger := zerolog.Ctx(ctx)
 	categoryFolders, err := readDir(dataFolder)
 	if err != nil {
@@ -361,7 +508,7 @@ func fillIndex(ctx context.Context, idx bleve.Index, dataFolder string) error {
 	wgParsers := sync.WaitGroup{}
 	wgParsers.Add(numParsers)
 	for i := 0; i < numParsers; i++ {
-		go runCollectionParser(cctx, &wgParsers, errs, parsedCollections, work)
+		go runCollectionParser(cctx, &wgParsers, errs, parsedCollections, work, r)
 	}
 
 	// Finally, let's start another go-routine that indexes the
@@ -376,26 +523,6 @@ func fillIndex(ctx context.Context, idx bleve.Index, dataFolder string) error {
 	return err
 }
 
-func updateRepo(ctx context.Context, p string) error {
-	cmd := exec.CommandContext(ctx, "git", "pull", "origin", "main")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Dir = p
-	return cmd.Run()
-}
-
-func getRepoState(ctx context.Context, p string) (string, error) {
-	logger := zerolog.Ctx(ctx)
-	logger.Info().Msgf("Checking HEAD of %s", p)
-	cmd := exec.CommandContext(ctx, "git", "rev-parse", "HEAD")
-	cmd.Dir = p
-	data, err := cmd.CombinedOutput()
-	if err != nil {
-		return "", err
-	}
-	return strings.TrimSpace(string(data)), err
-}
-
 func getIndexState(ctx context.Context, p string) (*State, error) {
 	sp := filepath.Join(p, stateFile)
 	state := State{}
@@ -410,6 +537,19 @@ func getIndexState(ctx context.Context, p string) (*State, error) {
 	return &state, nil
 }
 
+// newState returns the state of index built from the data at ref while
+// skipping the files listed in problems.
+func newState(index string, ref source.Ref, problems []Problem) *State {
+	return &State{
+		Ref:            ref.ID,
+		Index:          index,
+		MappingVersion: MappingVersion,
+		RefMode:        ref.Mode,
+		RefName:        ref.Name,
+		Problems:       problems,
+	}
+}
+
 func setIndexState(ctx context.Context, p string, state *State) error {
 	sp := filepath.Join(p, stateFile)
 	fp, err := os.OpenFile(sp, os.O_TRUNC|os.O_CREATE|os.O_RDWR, 0600)
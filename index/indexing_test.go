@@ -5,13 +5,18 @@ import (
 	"fmt"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"io/ioutil"
 	"os"
 
 	"github.com/Flaque/filet"
 	"github.com/blevesearch/bleve/v2"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/stretchr/testify/require"
+	"github.com/zerok/pyvideosearch/source"
 )
 
 var sessionContent = `{
@@ -28,7 +33,7 @@ func TestFillIndex(t *testing.T) {
 	root, _ := createConference(t, "conf-2017", []string{"my-session", "my-other-session"})
 	idx, _ := bleve.NewMemOnly(bleve.NewIndexMapping())
 
-	if err := fillIndex(context.Background(), idx, root); err != nil {
+	if err := fillIndex(context.Background(), idx, root, nil); err != nil {
 		t.Fatalf("Unexpected error when filling the index: %s", err.Error())
 	}
 
@@ -48,7 +53,7 @@ func TestFillIndexBrokenCategoryJSON(t *testing.T) {
 
 	idx, _ := bleve.NewMemOnly(bleve.NewIndexMapping())
 
-	if err := fillIndex(context.Background(), idx, root); err == nil {
+	if err := fillIndex(context.Background(), idx, root, nil); err == nil {
 		t.Fatal("Expected error not returned")
 	}
 }
@@ -78,6 +83,29 @@ func TestParseSession(t *testing.T) {
 		require.NoError(t, err)
 		require.Equal(t, "hello", s.Slug)
 	})
+
+	t.Run("metadata", func(t *testing.T) {
+		videoPath := filepath.Join(t.TempDir(), "video.json")
+		ioutil.WriteFile(videoPath, []byte(`{
+			"title": "Metadata",
+			"tags": ["asyncio", "web"],
+			"language": "eng",
+			"duration": 1834,
+			"related_urls": ["https://example.com/slides", {"label": "Code", "url": "https://example.com/code"}],
+			"copyright_text": "CC BY",
+			"summary": "Short",
+			"quality_notes": "Bad audio"
+		}`), 0600)
+		s, err := parseSession(videoPath)
+		require.NoError(t, err)
+		require.Equal(t, []string{"asyncio", "web"}, s.Tags)
+		require.Equal(t, "eng", s.Language)
+		require.Equal(t, 1834.0, s.Duration)
+		require.Equal(t, []RelatedURL{{URL: "https://example.com/slides"}, {Label: "Code", URL: "https://example.com/code"}}, s.RelatedURLs)
+		require.Equal(t, "CC BY", s.CopyrightText)
+		require.Equal(t, "Short", s.Summary)
+		require.Equal(t, "Bad audio", s.QualityNotes)
+	})
 }
 
 func createConference(t *testing.T, slug string, sessions []string) (string, string) {
@@ -99,3 +127,88 @@ func createConference(t *testing.T, slug string, sessions []string) (string, str
 func getVideoPath(root string, slug string) string {
 	return filepath.Join(root, "videos", fmt.Sprintf("%s.json", slug))
 }
+
+func TestRecordedRange(t *testing.T) {
+	i, _ := bleve.NewMemOnly(bleve.NewIndexMapping())
+	idx := &Index{Index: i}
+	defer idx.Close()
+
+	oldest, newest, err := idx.RecordedRange()
+	require.NoError(t, err)
+	require.True(t, oldest.IsZero())
+	require.True(t, newest.IsZero())
+
+	idx = &Index{Index: i}
+	require.NoError(t, i.Index("a", IndexedSession{Title: "A", Recorded: time.Date(2012, 3, 1, 0, 0, 0, 0, time.UTC)}))
+	require.NoError(t, i.Index("b", IndexedSession{Title: "B", Recorded: time.Date(2019, 7, 1, 0, 0, 0, 0, time.UTC)}))
+	require.NoError(t, i.Index("c", IndexedSession{Title: "C"}))
+	oldest, newest, err = idx.RecordedRange()
+	require.NoError(t, err)
+	require.Equal(t, 2012, oldest.Year())
+	require.Equal(t, 2019, newest.Year())
+}
+
+// TestLoadIndexFixedRef checks that the pinned ref is checked out before the
+// index is built or loaded even if the data is never updated afterwards.
+func TestLoadIndexFixedRef(t *testing.T) {
+	ctx := context.Background()
+	upstream := t.TempDir()
+	repo, err := git.PlainInit(upstream, false)
+	require.NoError(t, err)
+	worktree, err := repo.Worktree()
+	require.NoError(t, err)
+	commit := func(name string, content string) string {
+		writeDataFile(t, upstream, name, content)
+		_, err := worktree.Add(name)
+		require.NoError(t, err)
+		hash, err := worktree.Commit("Update data", &git.CommitOptions{
+			Author: &object.Signature{Name: "Test", Email: "test@example.org", When: time.Now()},
+		})
+		require.NoError(t, err)
+		return hash.String()
+	}
+	commit("conf-a/category.json", `{"title": "Conf A"}`)
+	pinned := commit("conf-a/videos/one.json", `{"title": "One"}`)
+	_, err = repo.CreateTag("v1", plumbing.NewHash(pinned), nil)
+	require.NoError(t, err)
+	commit("conf-a/videos/two.json", `{"title": "Two"}`)
+
+	local := t.TempDir()
+	_, err = git.PlainClone(local, false, &git.CloneOptions{URL: upstream})
+	require.NoError(t, err)
+	indexPath := t.TempDir()
+
+	// An index built from the newest commit is replaced on the next start.
+	head, err := source.NewGit(local, source.GitOptions{})
+	require.NoError(t, err)
+	idx, err := LoadIndex(ctx, indexPath, head, false, true, true)
+	require.NoError(t, err)
+	require.Equal(t, []string{"session:conf-a:one", "session:conf-a:two"}, documentIDs(t, idx.Index))
+	require.NoError(t, idx.Close())
+
+	for _, ref := range []string{pinned, "v1"} {
+		src, err := source.NewGit(local, source.GitOptions{Mode: source.RefModeFixed, FixedRef: ref})
+		require.NoError(t, err)
+		idx, err := LoadIndex(ctx, indexPath, src, false, true, true)
+		require.NoError(t, err)
+		require.Equal(t, []string{"session:conf-a:one"}, documentIDs(t, idx.Index))
+		require.NoError(t, idx.Close())
+
+		state, err := getIndexState(ctx, indexPath)
+		require.NoError(t, err)
+		require.Equal(t, pinned, state.Ref)
+		require.Equal(t, "fixed", state.RefMode)
+		require.Equal(t, ref, state.RefName)
+	}
+
+	// A new index is built from the pinned commit right away.
+	local = t.TempDir()
+	_, err = git.PlainClone(local, false, &git.CloneOptions{URL: upstream})
+	require.NoError(t, err)
+	src, err := source.NewGit(local, source.GitOptions{Mode: source.RefModeFixed, FixedRef: "v1"})
+	require.NoError(t, err)
+	idx, err = LoadIndex(ctx, t.TempDir(), src, false, true, true)
+	require.NoError(t, err)
+	require.Equal(t, []string{"session:conf-a:one"}, documentIDs(t, idx.Index))
+	require.NoError(t, idx.Close())
+}
@@ -0,0 +1,127 @@
+package index
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/blevesearch/bleve/v2/analysis/lang/cjk"
+	"github.com/blevesearch/bleve/v2/analysis/lang/de"
+	"github.com/blevesearch/bleve/v2/analysis/lang/en"
+	"github.com/blevesearch/bleve/v2/analysis/lang/es"
+	"github.com/blevesearch/bleve/v2/analysis/lang/fr"
+	"github.com/blevesearch/bleve/v2/analysis/lang/it"
+	"github.com/blevesearch/bleve/v2/analysis/lang/pt"
+	"github.com/blevesearch/bleve/v2/analysis/lang/ru"
+)
+
+// AnalysisLanguages are the languages for which titles and descriptions are
+// additionally indexed with language-specific stemming and stop words. The
+// values are the names of the bleve analyzers.
+var AnalysisLanguages = []string{en.AnalyzerName, es.AnalyzerName, pt.AnalyzerName, de.AnalyzerName, fr.AnalyzerName, it.AnalyzerName, ru.AnalyzerName, cjk.AnalyzerName}
+
+// languageFields are the fields that are analyzed per language.
+var languageFields = []string{"title", "description"}
+
+// LanguageVariants returns the names of the language-specific variants of
+// field. It returns nil if field isn't analyzed per language.
+func LanguageVariants(field string) []string {
+	var variants []string
+	for _, f := range languageFields {
+		if f != field {
+			continue
+		}
+		for _, language := range AnalysisLanguages {
+			variants = append(variants, languageField(field, language))
+		}
+	}
+	return variants
+}
+
+func languageField(field string, language string) string {
+	return field + "_" + language
+}
+
+// languageCodes maps the values of the language field in the data files to
+// analysis languages. The data mostly uses ISO 639-2 codes but some files
+// contain the name of the language instead.
+var languageCodes = map[string]string{
+	"eng": en.AnalyzerName, "en": en.AnalyzerName, "english": en.AnalyzerName,
+	"spa": es.AnalyzerName, "es": es.AnalyzerName, "spanish": es.AnalyzerName,
+	"por": pt.AnalyzerName, "pt": pt.AnalyzerName, "portuguese": pt.AnalyzerName,
+	"deu": de.AnalyzerName, "ger": de.AnalyzerName, "de": de.AnalyzerName, "german": de.AnalyzerName,
+	"fra": fr.AnalyzerName, "fre": fr.AnalyzerName, "fr": fr.AnalyzerName, "french": fr.AnalyzerName,
+	"ita": it.AnalyzerName, "it": it.AnalyzerName, "italian": it.AnalyzerName,
+	"rus": ru.AnalyzerName, "ru": ru.AnalyzerName, "russian": ru.AnalyzerName,
+	"jpn": cjk.AnalyzerName, "ja": cjk.AnalyzerName, "japanese": cjk.AnalyzerName,
+	"zho": cjk.AnalyzerName, "chi": cjk.AnalyzerName, "zh": cjk.AnalyzerName, "chinese": cjk.AnalyzerName,
+	"kor": cjk.AnalyzerName, "ko": cjk.AnalyzerName, "korean": cjk.AnalyzerName,
+}
+
+// stopWords are frequent words that are used to guess the language of
+// sessions without an explicit language.
+var stopWords = map[string][]string{
+	en.AnalyzerName: {"the", "and", "of", "to", "in", "is", "for", "with", "how", "this", "that", "you", "we", "are", "your", "from"},
+	es.AnalyzerName: {"el", "la", "los", "las", "de", "del", "que", "y", "en", "con", "para", "una", "por", "es", "cómo", "como", "al"},
+	pt.AnalyzerName: {"o", "a", "os", "as", "de", "do", "da", "dos", "das", "que", "e", "em", "com", "para", "uma", "não", "por", "é", "como", "no", "na"},
+	de.AnalyzerName: {"der", "die", "das", "und", "mit", "ist", "für", "ein", "eine", "wie", "von", "den", "zu", "nicht", "auf", "im"},
+	fr.AnalyzerName: {"le", "la", "les", "des", "et", "de", "du", "un", "une", "pour", "avec", "est", "dans", "sur", "comment", "en"},
+	it.AnalyzerName: {"il", "la", "le", "gli", "di", "del", "della", "e", "che", "per", "con", "un", "una", "è", "come", "nel"},
+	ru.AnalyzerName: {"и", "в", "на", "с", "как", "что", "не", "для", "по", "это", "из"},
+}
+
+// minStopWordHits is the number of stop words a text needs to contain before
+// its language is guessed.
+const minStopWordHits = 2
+
+// analysisLanguage returns the analysis language of a session. If the
+// language isn't given or unknown, it is guessed from the text. An empty
+// string is returned if that isn't possible either.
+func analysisLanguage(language string, text string) string {
+	if a, ok := languageCodes[strings.ToLower(strings.TrimSpace(language))]; ok {
+		return a
+	}
+	return detectLanguage(text)
+}
+
+// detectLanguage guesses the language of text by counting stop words. Texts
+// mostly written in CJK scripts are detected by their characters instead.
+func detectLanguage(text string) string {
+	letters, cjkLetters := 0, 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			cjkLetters++
+		}
+	}
+	if letters > 0 && cjkLetters*4 >= letters {
+		return cjk.AnalyzerName
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	counts := make(map[string]int, len(words))
+	for _, word := range words {
+		counts[word]++
+	}
+	best, bestHits, tie := "", 0, false
+	for _, language := range AnalysisLanguages {
+		hits := 0
+		for _, word := range stopWords[language] {
+			hits += counts[word]
+		}
+		switch {
+		case hits > bestHits:
+			best, bestHits, tie = language, hits, false
+		case hits == bestHits:
+			tie = true
+		}
+	}
+	if bestHits < minStopWordHits || tie {
+		return ""
+	}
+	return best
+}
@@ -0,0 +1,30 @@
+package index
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalysisLanguage(t *testing.T) {
+	testcases := []struct {
+		language string
+		text     string
+		expected string
+	}{
+		{language: "spa", text: "", expected: "es"},
+		{language: "English", text: "", expected: "en"},
+		{language: "jpn", text: "", expected: "cjk"},
+		{language: "", text: "Introducción al aprendizaje automático con Python y scikit-learn", expected: "es"},
+		{language: "", text: "Wie man mit der Standardbibliothek und asyncio arbeitet", expected: "de"},
+		{language: "", text: "How to get the most out of your tests", expected: "en"},
+		{language: "", text: "Pythonで始める機械学習", expected: "cjk"},
+		{language: "", text: "Asyncio", expected: ""},
+		{language: "klingon", text: "Python", expected: ""},
+	}
+	for _, testcase := range testcases {
+		t.Run(testcase.language+testcase.text, func(t *testing.T) {
+			require.Equal(t, testcase.expected, analysisLanguage(testcase.language, testcase.text))
+		})
+	}
+}
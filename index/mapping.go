@@ -0,0 +1,201 @@
+package index
+
+import (
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/custom"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/v2/analysis/token/edgengram"
+	"github.com/blevesearch/bleve/v2/analysis/token/lowercase"
+	"github.com/blevesearch/bleve/v2/analysis/tokenizer/single"
+	"github.com/blevesearch/bleve/v2/analysis/tokenizer/unicode"
+	"github.com/blevesearch/bleve/v2/mapping"
+)
+
+// lowercaseKeywordAnalyzer treats the whole value as a single token but
+// ignores the case. It is used for sorting.
+const lowercaseKeywordAnalyzer = "keyword_lowercase"
+
+// SuggestAnalyzer splits values into lowercase words and indexes all their
+// prefixes for autocompletion.
+const SuggestAnalyzer = "suggest"
+
+// SuggestQueryAnalyzer has to be used to analyze the input of suggestion
+// queries so that it isn't split into prefixes again.
+const SuggestQueryAnalyzer = "suggest_query"
+
+const suggestEdgeNgramFilter = "suggest_edge_ngram"
+
+// MappingVersion has to be increased whenever the mapping changes so that
+// existing indices are rebuilt instead of updated.
+const MappingVersion = 1
+
+// sessionType is the name of the document mapping for sessions. Sessions
+// with a known language use the mapping sessionType_<language>.
+const sessionType = "session"
+
+// NewMapping returns the mapping used for all session indices. Names and
+// slugs of speakers and collections are indexed as keywords so that facets
+// and filters work on whole values rather than on individual words. For the
+// free-text search they are additionally indexed as text.
+func NewMapping() mapping.IndexMapping {
+	m := bleve.NewIndexMapping()
+	mustRegister(m.AddCustomTokenFilter(suggestEdgeNgramFilter, map[string]interface{}{
+		"type": edgengram.Name,
+		"min":  1.0,
+		"max":  20.0,
+	}))
+	mustRegister(m.AddCustomAnalyzer(lowercaseKeywordAnalyzer, map[string]interface{}{
+		"type":          custom.Name,
+		"tokenizer":     single.Name,
+		"token_filters": []string{lowercase.Name},
+	}))
+	mustRegister(m.AddCustomAnalyzer(SuggestAnalyzer, map[string]interface{}{
+		"type":          custom.Name,
+		"tokenizer":     unicode.Name,
+		"token_filters": []string{lowercase.Name, suggestEdgeNgramFilter},
+	}))
+	mustRegister(m.AddCustomAnalyzer(SuggestQueryAnalyzer, map[string]interface{}{
+		"type":          custom.Name,
+		"tokenizer":     unicode.Name,
+		"token_filters": []string{lowercase.Name},
+	}))
+	m.AddDocumentMapping(sessionType, newSessionMapping(""))
+	for _, language := range AnalysisLanguages {
+		m.AddDocumentMapping(sessionType+"_"+language, newSessionMapping(language))
+	}
+	m.DefaultMapping = newSessionMapping("")
+	return m
+}
+
+// newSessionMapping returns the mapping of a session. If language is set,
+// the title and the description are additionally analyzed with the analyzer
+// of that language.
+func newSessionMapping(language string) *mapping.DocumentMapping {
+	sessionMapping := bleve.NewDocumentStaticMapping()
+	title := []*mapping.FieldMapping{textField(true), namedField("title_sort", sortField()), namedField("title_suggest", suggestField())}
+	description := []*mapping.FieldMapping{textField(true)}
+	if language != "" {
+		title = append(title, namedField(languageField("title", language), languageTextField(language)))
+		description = append(description, namedField(languageField("description", language), languageTextField(language)))
+	}
+	sessionMapping.AddFieldMappingsAt("title", title...)
+	sessionMapping.AddFieldMappingsAt("description", description...)
+	sessionMapping.AddFieldMappingsAt("collection_title", keywordField(), namedField("collection_title_text", textField(false)), namedField("collection_title_suggest", suggestField()))
+	sessionMapping.AddFieldMappingsAt("collection_slug", keywordField())
+	sessionMapping.AddFieldMappingsAt("recorded", dateTimeField())
+	sessionMapping.AddFieldMappingsAt("has_video", booleanField())
+	sessionMapping.AddFieldMappingsAt("tags", keywordField(), namedField("tags_text", textField(false)))
+	sessionMapping.AddFieldMappingsAt("language", keywordField())
+	sessionMapping.AddFieldMappingsAt("duration", numericField())
+	sessionMapping.AddFieldMappingsAt("summary", textField(true))
+	sessionMapping.AddFieldMappingsAt("source_path", keywordField())
+	sessionMapping.AddFieldMappingsAt("collection_folder", keywordField())
+	for _, name := range []string{"url", "collection_url", "thumbnail_url", "recorded_formatted", "copyright_text", "quality_notes"} {
+		sessionMapping.AddFieldMappingsAt(name, storedField())
+	}
+
+	speakerMapping := bleve.NewDocumentStaticMapping()
+	speakerMapping.AddFieldMappingsAt("name", keywordField(), namedField("name_text", textField(false)), namedField("name_suggest", suggestField()))
+	speakerMapping.AddFieldMappingsAt("slug", keywordField())
+	sessionMapping.AddSubDocumentMapping("speakers", speakerMapping)
+
+	videoMapping := bleve.NewDocumentStaticMapping()
+	videoMapping.AddFieldMappingsAt("type", storedField())
+	videoMapping.AddFieldMappingsAt("url", storedField())
+	sessionMapping.AddSubDocumentMapping("videos", videoMapping)
+
+	relatedURLMapping := bleve.NewDocumentStaticMapping()
+	relatedURLMapping.AddFieldMappingsAt("label", storedField())
+	relatedURLMapping.AddFieldMappingsAt("url", storedField())
+	sessionMapping.AddSubDocumentMapping("related_urls", relatedURLMapping)
+	return sessionMapping
+}
+
+// mustRegister panics if a custom analysis component of the static mapping
+// couldn't be registered. This can only happen due to a programming error.
+func mustRegister(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+// textField is analyzed for the free-text search. Only fields that are
+// returned to clients have to be stored.
+func textField(store bool) *mapping.FieldMapping {
+	fm := bleve.NewTextFieldMapping()
+	fm.Store = store
+	fm.DocValues = false
+	return fm
+}
+
+// languageTextField is analyzed with stemming and stop words of a language.
+// It is searched explicitly and not part of the composite field.
+func languageTextField(language string) *mapping.FieldMapping {
+	fm := bleve.NewTextFieldMapping()
+	fm.Analyzer = language
+	fm.Store = false
+	fm.IncludeInAll = false
+	fm.IncludeTermVectors = false
+	fm.DocValues = false
+	return fm
+}
+
+func keywordField() *mapping.FieldMapping {
+	fm := bleve.NewTextFieldMapping()
+	fm.Analyzer = keyword.Name
+	fm.IncludeInAll = false
+	fm.IncludeTermVectors = false
+	return fm
+}
+
+func sortField() *mapping.FieldMapping {
+	fm := bleve.NewTextFieldMapping()
+	fm.Analyzer = lowercaseKeywordAnalyzer
+	fm.Store = false
+	fm.IncludeInAll = false
+	fm.IncludeTermVectors = false
+	return fm
+}
+
+func dateTimeField() *mapping.FieldMapping {
+	fm := bleve.NewDateTimeFieldMapping()
+	fm.IncludeInAll = false
+	return fm
+}
+
+func numericField() *mapping.FieldMapping {
+	fm := bleve.NewNumericFieldMapping()
+	fm.IncludeInAll = false
+	return fm
+}
+
+func booleanField() *mapping.FieldMapping {
+	fm := bleve.NewBooleanFieldMapping()
+	fm.IncludeInAll = false
+	return fm
+}
+
+func suggestField() *mapping.FieldMapping {
+	fm := bleve.NewTextFieldMapping()
+	fm.Analyzer = SuggestAnalyzer
+	fm.Store = false
+	fm.IncludeInAll = false
+	fm.IncludeTermVectors = false
+	fm.DocValues = false
+	return fm
+}
+
+// storedField is only returned with the search results but not searchable.
+func storedField() *mapping.FieldMapping {
+	fm := bleve.NewTextFieldMapping()
+	fm.Index = false
+	fm.IncludeInAll = false
+	fm.IncludeTermVectors = false
+	fm.DocValues = false
+	return fm
+}
+
+func namedField(name string, fm *mapping.FieldMapping) *mapping.FieldMapping {
+	fm.Name = name
+	return fm
+}
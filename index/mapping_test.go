@@ -0,0 +1,74 @@
+package index
+
+import (
+	"testing"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMapping(t *testing.T) {
+	idx, err := bleve.NewMemOnly(NewMapping())
+	require.NoError(t, err)
+	defer idx.Close()
+	require.NoError(t, idx.Index("session:pycon-us-2017:hello", IndexedSession{
+		Title:           "Hello world",
+		CollectionTitle: "PyCon US 2017",
+		CollectionSlug:  "pycon-us-2017",
+		URL:             "/pycon-us-2017/hello.html",
+		Speakers: []Speaker{
+			{Name: "Jane Doe", Slug: "jane-doe"},
+			{Name: "John Smith", Slug: "john-smith"},
+		},
+	}))
+
+	t.Run("facets-use-whole-names", func(t *testing.T) {
+		req := bleve.NewSearchRequest(bleve.NewMatchAllQuery())
+		req.AddFacet("speaker", bleve.NewFacetRequest("speakers.name", 10))
+		req.AddFacet("collection", bleve.NewFacetRequest("collection_title", 10))
+		res, err := idx.Search(req)
+		require.NoError(t, err)
+		speakers := []string{}
+		for _, term := range res.Facets["speaker"].Terms.Terms() {
+			speakers = append(speakers, term.Term)
+		}
+		require.ElementsMatch(t, []string{"Jane Doe", "John Smith"}, speakers)
+		require.Equal(t, "PyCon US 2017", res.Facets["collection"].Terms.Terms()[0].Term)
+	})
+
+	t.Run("filters-match-exactly", func(t *testing.T) {
+		q := bleve.NewTermQuery("jane-doe")
+		q.SetField("speakers.slug")
+		res, err := idx.Search(bleve.NewSearchRequest(q))
+		require.NoError(t, err)
+		require.Equal(t, uint64(1), res.Total)
+
+		q = bleve.NewTermQuery("jane")
+		q.SetField("speakers.slug")
+		res, err = idx.Search(bleve.NewSearchRequest(q))
+		require.NoError(t, err)
+		require.Equal(t, uint64(0), res.Total)
+	})
+
+	t.Run("names-are-searchable", func(t *testing.T) {
+		for _, qs := range []string{"doe", "pycon", "hello"} {
+			res, err := idx.Search(bleve.NewSearchRequest(bleve.NewQueryStringQuery(qs)))
+			require.NoError(t, err)
+			require.Equal(t, uint64(1), res.Total, qs)
+		}
+	})
+
+	t.Run("urls-are-stored-only", func(t *testing.T) {
+		q := bleve.NewMatchQuery("pycon")
+		q.SetField("url")
+		res, err := idx.Search(bleve.NewSearchRequest(q))
+		require.NoError(t, err)
+		require.Equal(t, uint64(0), res.Total)
+
+		req := bleve.NewSearchRequest(bleve.NewMatchAllQuery())
+		req.Fields = []string{"url"}
+		res, err = idx.Search(req)
+		require.NoError(t, err)
+		require.Equal(t, "/pycon-us-2017/hello.html", res.Hits[0].Fields["url"])
+	})
+}
@@ -0,0 +1,166 @@
+package index
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// errSkipped is returned by parseCollection if the collection was skipped
+// because its category.json is broken.
+var errSkipped = errors.New("Skipped")
+
+// Problem describes a data file that was skipped because it couldn't be
+// parsed.
+type Problem struct {
+	// File is the path relative to the data folder.
+	File  string `json:"file"`
+	Error string `json:"error"`
+	// Line and Column point to the location of syntax and type errors.
+	// Both start at 1 and are 0 if unknown.
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+}
+
+// reporter collects the problems of data files skipped while indexing. A
+// nil reporter makes parsing strict: the first broken file fails the
+// whole build.
+type reporter struct {
+	root string
+
+	lock     sync.Mutex
+	problems []Problem
+}
+
+func newReporter(root string, strict bool) *reporter {
+	if strict {
+		return nil
+	}
+	return &reporter{root: root, problems: make([]Problem, 0)}
+}
+
+// skip records the problem of the file p and reports whether the file may
+// be skipped.
+func (r *reporter) skip(ctx context.Context, p string, err error) bool {
+	if r == nil {
+		return false
+	}
+	problem := newProblem(r.root, p, err)
+	zerolog.Ctx(ctx).Warn().Str("file", problem.File).Int("line", problem.Line).Int("column", problem.Column).Msgf("Skipping broken file: %s", problem.Error)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.problems = append(r.problems, problem)
+	return true
+}
+
+// report returns the recorded problems ordered by file.
+func (r *reporter) report() []Problem {
+	if r == nil {
+		return []Problem{}
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	problems := append([]Problem{}, r.problems...)
+	sortProblems(problems)
+	return problems
+}
+
+func sortProblems(problems []Problem) {
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].File < problems[j].File
+	})
+}
+
+func newProblem(root string, p string, err error) Problem {
+	problem := Problem{File: filepath.ToSlash(p), Error: errors.Cause(err).Error()}
+	if rel, relErr := filepath.Rel(root, p); relErr == nil {
+		problem.File = filepath.ToSlash(rel)
+	}
+	var jsonErr *jsonError
+	if errors.As(err, &jsonErr) {
+		problem.Error = jsonErr.err.Error()
+		problem.Line, problem.Column = jsonErr.line, jsonErr.column
+	}
+	return problem
+}
+
+// jsonError is a syntax or type error together with its location.
+type jsonError struct {
+	err    error
+	line   int
+	column int
+}
+
+func (e *jsonError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.line, e.column, e.err)
+}
+
+func (e *jsonError) Unwrap() error {
+	return e.err
+}
+
+// decodeJSONFile decodes the JSON file p into v. Syntax and type errors are
+// returned as jsonError.
+func decodeJSONFile(p string, v interface{}) error {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(data, v)
+	var offset int64 = -1
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	}
+	if offset < 0 {
+		return err
+	}
+	line, column := position(data, offset)
+	return &jsonError{err: err, line: line, column: column}
+}
+
+// position converts a byte offset into a line and a column.
+func position(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len([]rune(string(before[bytes.LastIndexByte(before, '\n')+1:])))
+	if column == 0 {
+		column = 1
+	}
+	return line, column
+}
+
+// mergeProblems replaces the problems of all files affected by changes with
+// the ones found while applying them.
+func mergeProblems(old []Problem, changes changeSet, found []Problem) []Problem {
+	foundFiles := make(map[string]bool, len(found))
+	for _, problem := range found {
+		foundFiles[problem.File] = true
+	}
+	merged := make([]Problem, 0, len(old)+len(found))
+	for _, problem := range old {
+		if foundFiles[problem.File] || changes.sessions[problem.File] || changes.collections[strings.SplitN(problem.File, "/", 2)[0]] {
+			continue
+		}
+		merged = append(merged, problem)
+	}
+	merged = append(merged, found...)
+	sortProblems(merged)
+	return merged
+}
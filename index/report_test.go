@@ -0,0 +1,81 @@
+package index
+
+import (
+	"context"
+	"testing"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFillIndexLenient(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	writeDataFile(t, root, "conf-a/category.json", `{"title": "Conf A"}`)
+	writeDataFile(t, root, "conf-a/videos/one.json", `{"title": "One"}`)
+	writeDataFile(t, root, "conf-a/videos/two.json", "{\n  \"title\": \"Two\",\n  \"speakers\": [\"A\"\n}")
+	writeDataFile(t, root, "conf-a/videos/three.json", "{\n  \"title\": 3\n}")
+	writeDataFile(t, root, "conf-b/category.json", `not json`)
+	writeDataFile(t, root, "conf-b/videos/four.json", `{"title": "Four"}`)
+
+	i, err := bleve.NewMemOnly(NewMapping())
+	require.NoError(t, err)
+	defer i.Close()
+	require.Error(t, fillIndex(ctx, i, root, nil))
+
+	r := newReporter(root, false)
+	require.NoError(t, fillIndex(ctx, i, root, r))
+	require.Equal(t, []string{"session:conf-a:one"}, documentIDs(t, i))
+
+	problems := r.report()
+	require.Len(t, problems, 3)
+	require.Equal(t, Problem{File: "conf-a/videos/three.json", Error: problems[0].Error, Line: 2, Column: 12}, problems[0])
+	require.Contains(t, problems[0].Error, "cannot unmarshal number")
+	require.Equal(t, Problem{File: "conf-a/videos/two.json", Error: problems[1].Error, Line: 4, Column: 1}, problems[1])
+	require.Contains(t, problems[1].Error, "invalid character '}'")
+	require.Equal(t, Problem{File: "conf-b/category.json", Error: problems[2].Error, Line: 1, Column: 2}, problems[2])
+}
+
+func TestUpdateIndexLenient(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	writeDataFile(t, root, "conf-a/category.json", `{"title": "Conf A"}`)
+	writeDataFile(t, root, "conf-a/videos/one.json", `{"title": "One"}`)
+	writeDataFile(t, root, "conf-a/videos/two.json", `{"title": `)
+	writeDataFile(t, root, "conf-b/category.json", `{"title": `)
+	writeDataFile(t, root, "conf-b/videos/four.json", `{"title": "Four"}`)
+
+	i, err := bleve.NewMemOnly(NewMapping())
+	require.NoError(t, err)
+	idx := &Index{Index: i}
+	defer idx.Close()
+	r := newReporter(root, false)
+	require.NoError(t, fillIndex(ctx, i, root, r))
+	idx.setProblems(r.report())
+	require.Len(t, idx.Problems(), 2)
+
+	writeDataFile(t, root, "conf-a/videos/two.json", `{"title": "Two"}`)
+	writeDataFile(t, root, "conf-a/videos/three.json", `[]`)
+	changes := newChangeSet([]string{"conf-a/videos/two.json", "conf-a/videos/three.json"})
+	require.Error(t, updateIndex(ctx, idx, root, changes, true))
+	require.NoError(t, updateIndex(ctx, idx, root, changes, false))
+	require.Equal(t, []string{"session:conf-a:one", "session:conf-a:two"}, documentIDs(t, i))
+
+	problems := idx.Problems()
+	require.Len(t, problems, 2)
+	require.Equal(t, "conf-a/videos/three.json", problems[0].File)
+	require.Equal(t, "conf-b/category.json", problems[1].File)
+
+	writeDataFile(t, root, "conf-b/category.json", `{"title": "Conf B"}`)
+	require.NoError(t, updateIndex(ctx, idx, root, newChangeSet([]string{"conf-b/category.json"}), false))
+	require.Equal(t, []string{"conf-a/videos/three.json"}, problemFiles(idx.Problems()))
+	require.Equal(t, []string{"session:conf-a:one", "session:conf-a:two", "session:conf-b:four"}, documentIDs(t, i))
+}
+
+func problemFiles(problems []Problem) []string {
+	files := make([]string, 0, len(problems))
+	for _, problem := range problems {
+		files = append(files, problem.File)
+	}
+	return files
+}
@@ -0,0 +1,73 @@
+package index
+
+import (
+	"context"
+	"time"
+)
+
+// Trigger requests immediate updates from WatchForUpdates, e.g. after a
+// webhook reported a push. Requests arriving within the debounce delay of
+// each other result in a single update.
+type Trigger struct {
+	requests chan struct{}
+	delay    time.Duration
+}
+
+// NewTrigger returns a trigger that waits until no further request arrived
+// for delay before starting an update.
+func NewTrigger(delay time.Duration) *Trigger {
+	return &Trigger{
+		requests: make(chan struct{}, 1),
+		delay:    delay,
+	}
+}
+
+// Request schedules an update. It never blocks as requests are merged
+// until the update starts.
+func (t *Trigger) Request() {
+	select {
+	case t.requests <- struct{}{}:
+	default:
+	}
+}
+
+// settle waits until no request arrived for the debounce delay. It returns
+// false if ctx is done first.
+func (t *Trigger) settle(ctx context.Context) bool {
+	timer := time.NewTimer(t.delay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-t.requests:
+			timer.Reset(t.delay)
+		case <-timer.C:
+			return true
+		}
+	}
+}
+
+// waitForUpdate blocks until the interval elapsed or an update was
+// requested through trigger. Both are optional. It returns false if ctx is
+// done first.
+func waitForUpdate(ctx context.Context, interval time.Duration, trigger *Trigger) bool {
+	var tick <-chan time.Time
+	if interval > 0 {
+		timer := time.NewTimer(interval)
+		defer timer.Stop()
+		tick = timer.C
+	}
+	var requests <-chan struct{}
+	if trigger != nil {
+		requests = trigger.requests
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case <-tick:
+		return true
+	case <-requests:
+		return trigger.settle(ctx)
+	}
+}
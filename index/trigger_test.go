@@ -0,0 +1,30 @@
+package index
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaitForUpdate(t *testing.T) {
+	ctx := context.Background()
+	require.True(t, waitForUpdate(ctx, time.Millisecond, nil))
+
+	trigger := NewTrigger(20 * time.Millisecond)
+	go func() {
+		for i := 0; i < 3; i++ {
+			trigger.Request()
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+	require.True(t, waitForUpdate(ctx, time.Hour, trigger))
+	require.Empty(t, trigger.requests, "requests of a burst should be merged")
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	require.False(t, waitForUpdate(canceled, 0, trigger))
+	trigger.Request()
+	require.False(t, waitForUpdate(canceled, 0, trigger))
+}
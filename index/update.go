@@ -0,0 +1,200 @@
+package index
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/zerok/pyvideosearch/source"
+)
+
+// changeSet lists the parts of the data repository that have to be
+// reindexed. Paths are relative to the data folder and use slashes.
+type changeSet struct {
+	// collections contains the folders of collections whose category.json
+	// changed. All their sessions are reindexed.
+	collections map[string]bool
+	// sessions contains the paths of changed session files.
+	sessions map[string]bool
+}
+
+// newChangeSet sorts the changed files into collections and sessions.
+// Files that are neither a category.json nor a session file are ignored.
+func newChangeSet(files []string) changeSet {
+	changes := changeSet{
+		collections: make(map[string]bool),
+		sessions:    make(map[string]bool),
+	}
+	for _, file := range files {
+		parts := strings.Split(file, "/")
+		switch {
+		case len(parts) == 2 && parts[1] == categoryFile:
+			changes.collections[parts[0]] = true
+		case len(parts) == 3 && parts[1] == videosFolder && strings.HasSuffix(parts[2], ".json"):
+			changes.sessions[file] = true
+		}
+	}
+	for file := range changes.sessions {
+		if changes.collections[strings.SplitN(file, "/", 2)[0]] {
+			delete(changes.sessions, file)
+		}
+	}
+	return changes
+}
+
+func (c changeSet) empty() bool {
+	return len(c.collections) == 0 && len(c.sessions) == 0
+}
+
+// updateIndexInPlace applies the changes between the commit the index was
+// built from and ref to idx. It fails if the index can't be updated in
+// place and has to be rebuilt instead.
+func updateIndexInPlace(ctx context.Context, idx *Index, src source.Source, state *State, ref string, strict bool) error {
+	if idx == nil {
+		return errors.New("No index loaded")
+	}
+	if state.MappingVersion != MappingVersion {
+		return errors.Errorf("Index has mapping version %d instead of %d", state.MappingVersion, MappingVersion)
+	}
+	if state.Ref == "" || filepath.Base(idx.Path) != state.Index {
+		return errors.New("Loaded index doesn't match the index state")
+	}
+	files, err := src.Changes(ctx, state.Ref, ref)
+	if err != nil {
+		return err
+	}
+	return updateIndex(ctx, idx, src.Path(), newChangeSet(files), strict)
+}
+
+// updateIndex replaces the documents of all changed collections and
+// sessions in a single batch. Documents of files that no longer exist are
+// removed. Unless strict is set, broken files are skipped and the problems
+// of the index are updated accordingly.
+func updateIndex(ctx context.Context, idx *Index, dataPath string, changes changeSet, strict bool) error {
+	logger := zerolog.Ctx(ctx)
+	if changes.empty() {
+		return nil
+	}
+	r := newReporter(dataPath, strict)
+	batch := idx.Index.NewBatch()
+	deleted := 0
+	for _, folder := range sortedKeys(changes.collections) {
+		ids, err := findDocuments(idx.Index, "collection_folder", folder)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			batch.Delete(id)
+		}
+		deleted += len(ids)
+	}
+	for _, file := range sortedKeys(changes.sessions) {
+		ids, err := findDocuments(idx.Index, "source_path", file)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			batch.Delete(id)
+		}
+		deleted += len(ids)
+	}
+
+	indexed := 0
+	for _, folder := range sortedKeys(changes.collections) {
+		p := filepath.Join(dataPath, filepath.FromSlash(folder))
+		if _, err := os.Stat(filepath.Join(p, categoryFile)); os.IsNotExist(err) {
+			continue
+		}
+		collection, err := parseCollection(ctx, p, r)
+		if err == errSkipped {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, session := range collection.Sessions {
+			if err := batch.Index(SessionID(collection.Slug, session.Slug), newIndexedSession(ctx, &session, &collection)); err != nil {
+				return err
+			}
+			indexed++
+		}
+	}
+	collections := make(map[string]*Collection)
+	for _, file := range sortedKeys(changes.sessions) {
+		p := filepath.Join(dataPath, filepath.FromSlash(file))
+		if _, err := os.Stat(p); os.IsNotExist(err) {
+			continue
+		}
+		folder := strings.SplitN(file, "/", 2)[0]
+		collection, ok := collections[folder]
+		if !ok {
+			c, err := parseCategory(filepath.Join(dataPath, folder))
+			if err != nil {
+				if !r.skip(ctx, filepath.Join(dataPath, folder, categoryFile), err) {
+					return err
+				}
+			} else {
+				collection = &c
+			}
+			collections[folder] = collection
+		}
+		if collection == nil {
+			continue
+		}
+		session, err := parseSession(p)
+		if err != nil {
+			if r.skip(ctx, p, err) {
+				continue
+			}
+			return err
+		}
+		if err := batch.Index(SessionID(collection.Slug, session.Slug), newIndexedSession(ctx, &session, collection)); err != nil {
+			return err
+		}
+		indexed++
+	}
+
+	if err := idx.Index.Batch(batch); err != nil {
+		return errors.Wrap(err, "Failed to update index")
+	}
+	idx.resetCaches()
+	idx.setProblems(mergeProblems(idx.Problems(), changes, r.report()))
+	logger.Info().Int("deleted", deleted).Int("indexed", indexed).Msg("Index updated")
+	return nil
+}
+
+// findDocuments returns the IDs of all documents with the given keyword
+// value.
+func findDocuments(idx bleve.Index, field string, value string) ([]string, error) {
+	const pageSize = 1000
+	q := bleve.NewTermQuery(value)
+	q.SetField(field)
+	ids := make([]string, 0)
+	for {
+		req := bleve.NewSearchRequestOptions(q, pageSize, len(ids), false)
+		res, err := idx.Search(req)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to find documents of %s", value)
+		}
+		for _, hit := range res.Hits {
+			ids = append(ids, hit.ID)
+		}
+		if len(res.Hits) < pageSize {
+			return ids, nil
+		}
+	}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
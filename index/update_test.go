@@ -0,0 +1,114 @@
+package index
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/stretchr/testify/require"
+	"github.com/zerok/pyvideosearch/source"
+)
+
+func TestNewChangeSet(t *testing.T) {
+	changes := newChangeSet([]string{
+		"README.md",
+		"conf-a/category.json",
+		"conf-a/videos/one.json",
+		"conf-b/videos/two.json",
+		"conf-b/videos/notes.txt",
+		"conf-b/videos/nested/three.json",
+	})
+	require.Equal(t, map[string]bool{"conf-a": true}, changes.collections)
+	require.Equal(t, map[string]bool{"conf-b/videos/two.json": true}, changes.sessions)
+	require.True(t, newChangeSet([]string{"README.md"}).empty())
+}
+
+func TestUpdateIndex(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	writeDataFile(t, root, "conf-a/category.json", `{"title": "Conf A"}`)
+	writeDataFile(t, root, "conf-a/videos/one.json", `{"title": "One", "recorded": "2015-01-01"}`)
+	writeDataFile(t, root, "conf-a/videos/two.json", `{"title": "Two", "recorded": "2016-01-01"}`)
+	writeDataFile(t, root, "conf-b/category.json", `{"title": "Conf B"}`)
+	writeDataFile(t, root, "conf-b/videos/three.json", `{"title": "Three", "recorded": "2017-01-01"}`)
+
+	i, err := bleve.NewMemOnly(NewMapping())
+	require.NoError(t, err)
+	idx := &Index{Index: i}
+	defer idx.Close()
+	require.NoError(t, fillIndex(ctx, i, root, nil))
+	require.Equal(t, []string{"session:conf-a:one", "session:conf-a:two", "session:conf-b:three"}, documentIDs(t, i))
+	_, newest, err := idx.RecordedRange()
+	require.NoError(t, err)
+	require.Equal(t, 2017, newest.Year())
+
+	t.Run("sessions", func(t *testing.T) {
+		writeDataFile(t, root, "conf-a/videos/one.json", `{"title": "One renamed"}`)
+		require.NoError(t, os.Remove(filepath.Join(root, "conf-a/videos/two.json")))
+		writeDataFile(t, root, "conf-a/videos/four.json", `{"title": "Four", "recorded": "2020-01-01"}`)
+		changes := newChangeSet([]string{"conf-a/videos/one.json", "conf-a/videos/two.json", "conf-a/videos/four.json"})
+		require.NoError(t, updateIndex(ctx, idx, root, changes, true))
+		require.Equal(t, []string{"session:conf-a:four", "session:conf-a:one-renamed", "session:conf-b:three"}, documentIDs(t, i))
+
+		_, newest, err := idx.RecordedRange()
+		require.NoError(t, err)
+		require.Equal(t, 2020, newest.Year())
+	})
+
+	t.Run("collections", func(t *testing.T) {
+		writeDataFile(t, root, "conf-b/category.json", `{"title": "Conf B", "slug": "conf-b-2017"}`)
+		require.NoError(t, os.RemoveAll(filepath.Join(root, "conf-a")))
+		changes := newChangeSet([]string{"conf-a/category.json", "conf-a/videos/four.json", "conf-b/category.json"})
+		require.NoError(t, updateIndex(ctx, idx, root, changes, true))
+		require.Equal(t, []string{"session:conf-b-2017:three"}, documentIDs(t, i))
+	})
+}
+
+func TestUpdateIndexInPlace(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	writeDataFile(t, root, "conf-a/category.json", `{"title": "Conf A"}`)
+	writeDataFile(t, root, "conf-a/videos/one.json", `{"title": "One"}`)
+	src := source.NewDirectory(root)
+
+	i, err := bleve.NewMemOnly(NewMapping())
+	require.NoError(t, err)
+	idx := &Index{Index: i, Path: filepath.Join(t.TempDir(), "current")}
+	defer idx.Close()
+	require.NoError(t, fillIndex(ctx, i, root, nil))
+	ref, err := src.Ref(ctx)
+	require.NoError(t, err)
+	state := newState("current", ref, nil)
+
+	writeDataFile(t, root, "conf-a/videos/two.json", `{"title": "Two"}`)
+	ref, err = src.Ref(ctx)
+	require.NoError(t, err)
+	require.NoError(t, updateIndexInPlace(ctx, idx, src, state, ref.ID, true))
+	require.Equal(t, []string{"session:conf-a:one", "session:conf-a:two"}, documentIDs(t, i))
+
+	outdated := *state
+	outdated.MappingVersion = MappingVersion - 1
+	require.Error(t, updateIndexInPlace(ctx, idx, src, &outdated, ref.ID, true))
+	require.Error(t, updateIndexInPlace(ctx, idx, src, newState("other", ref, nil), ref.ID, true))
+}
+
+func writeDataFile(t *testing.T, root string, name string, content string) {
+	p := filepath.Join(root, filepath.FromSlash(name))
+	require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+	require.NoError(t, os.WriteFile(p, []byte(content), 0600))
+}
+
+func documentIDs(t *testing.T, idx bleve.Index) []string {
+	req := bleve.NewSearchRequestOptions(bleve.NewMatchAllQuery(), 100, 0, false)
+	res, err := idx.Search(req)
+	require.NoError(t, err)
+	ids := make([]string, 0, len(res.Hits))
+	for _, hit := range res.Hits {
+		ids = append(ids, hit.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
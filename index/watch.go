@@ -0,0 +1,177 @@
+package index
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/zerok/pyvideosearch/source"
+)
+
+// WatchFolder updates idx as soon as a category.json or a session file
+// within the data folder of src is saved and sends it to idxChan. Changes
+// are collected until no further change happened for delay, as editors
+// often write a file in several steps. Files that can't be parsed are
+// logged and skipped until they are saved again. Unless strict is set,
+// they are also listed in the problems of the index.
+func WatchFolder(ctx context.Context, idxChan chan *Index, idx *Index, indexPath string, src source.Source, delay time.Duration, strict bool, deleteOldIndex bool) error {
+	return watchFolder(ctx, idxChan, idx, indexPath, src, delay, strict, deleteOldIndex, nil)
+}
+
+// watchFolder implements WatchFolder. If ready isn't nil, it is called
+// once all folders are watched.
+func watchFolder(ctx context.Context, idxChan chan *Index, idx *Index, indexPath string, src source.Source, delay time.Duration, strict bool, deleteOldIndex bool, ready func()) error {
+	logger := zerolog.Ctx(ctx)
+	root := src.Path()
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrap(err, "Failed to create file watcher")
+	}
+	defer watcher.Close()
+	if err := watchTree(watcher, root, root); err != nil {
+		return err
+	}
+
+	// Files changed while the index wasn't watched are only noticed by
+	// comparing refs.
+	newIdx, changed, err := syncIndex(ctx, idx, indexPath, src, strict, deleteOldIndex)
+	if err != nil {
+		return err
+	}
+	if changed {
+		idx = newIdx
+		idxChan <- idx
+	}
+	logger.Info().Msgf("Watching %s for changes", root)
+	if ready != nil {
+		ready()
+	}
+
+	pending := make(map[string]bool)
+	timer := time.NewTimer(delay)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			logger.Error().Err(err).Msg("File watcher failed")
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			file, ok := watchedFile(watcher, root, event)
+			if !ok {
+				continue
+			}
+			pending[file] = true
+			timer.Reset(delay)
+		case <-timer.C:
+			files := make([]string, 0, len(pending))
+			for file := range pending {
+				files = append(files, file)
+			}
+			pending = make(map[string]bool)
+			if err := updateIndex(ctx, idx, root, newChangeSet(files), strict); err != nil {
+				// Apply the files one by one so that a file that is
+				// still being edited doesn't hold back the others.
+				for _, file := range files {
+					if err := updateIndex(ctx, idx, root, newChangeSet([]string{file}), strict); err != nil {
+						logger.Error().Err(err).Msgf("Failed to update index with %s", file)
+					}
+				}
+			}
+			if err := markIndexModified(ctx, indexPath, idx); err != nil {
+				return err
+			}
+			idxChan <- idx
+		}
+	}
+}
+
+// watchTree watches p and, if p is the data folder or a collection folder,
+// all folders within it. fsnotify doesn't watch folders recursively.
+func watchTree(watcher *fsnotify.Watcher, root string, p string) error {
+	if err := watcher.Add(p); err != nil {
+		return errors.Wrapf(err, "Failed to watch %s", p)
+	}
+	if depth(root, p) >= 2 {
+		return nil
+	}
+	entries, err := os.ReadDir(p)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to read %s", p)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			if err := watchTree(watcher, root, filepath.Join(p, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// watchedFile returns the path of the changed file relative to the data
+// folder as expected by newChangeSet. Changes of whole folders are
+// reported as a change of the collection's category.json so that all its
+// sessions are reindexed. New folders are watched as well.
+func watchedFile(watcher *fsnotify.Watcher, root string, event fsnotify.Event) (string, bool) {
+	if event.Op == fsnotify.Chmod {
+		return "", false
+	}
+	rel, err := filepath.Rel(root, event.Name)
+	if err != nil {
+		return "", false
+	}
+	rel = filepath.ToSlash(rel)
+	if strings.HasPrefix(path.Base(rel), ".") {
+		return "", false
+	}
+	parts := strings.Split(rel, "/")
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			watchTree(watcher, root, event.Name)
+			return parts[0] + "/" + categoryFile, true
+		}
+	}
+	switch {
+	case len(parts) == 1 && (event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename)):
+		return rel + "/" + categoryFile, true
+	case len(parts) == 2 && parts[1] == videosFolder:
+		return parts[0] + "/" + categoryFile, true
+	}
+	return rel, true
+}
+
+// depth returns the number of path elements p is below root.
+func depth(root string, p string) int {
+	rel, err := filepath.Rel(root, p)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return len(strings.Split(filepath.ToSlash(rel), "/"))
+}
+
+// markIndexModified clears the ref in the index state as the index no
+// longer matches any state of the source. It is rebuilt on the next start.
+// The problems of idx are stored as well.
+func markIndexModified(ctx context.Context, indexPath string, idx *Index) error {
+	state, err := getIndexState(ctx, indexPath)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to get index state of %s", indexPath)
+	}
+	state.Ref = ""
+	state.Problems = idx.Problems()
+	return setIndexState(ctx, indexPath, state)
+}
@@ -0,0 +1,83 @@
+package index
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/stretchr/testify/require"
+	"github.com/zerok/pyvideosearch/source"
+)
+
+func TestWatchFolder(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	root := t.TempDir()
+	indexPath := t.TempDir()
+	writeDataFile(t, root, "conf-a/category.json", `{"title": "Conf A"}`)
+	writeDataFile(t, root, "conf-a/videos/one.json", `{"title": "One"}`)
+	src := source.NewDirectory(root)
+
+	i, err := bleve.NewMemOnly(NewMapping())
+	require.NoError(t, err)
+	idx := &Index{Index: i, Path: filepath.Join(indexPath, "current")}
+	defer idx.Close()
+	require.NoError(t, fillIndex(ctx, i, root, nil))
+	ref, err := src.Ref(ctx)
+	require.NoError(t, err)
+	require.NoError(t, setIndexState(ctx, indexPath, newState("current", ref, nil)))
+
+	idxChan := make(chan *Index)
+	errs := make(chan error, 1)
+	ready := make(chan struct{})
+	go func() {
+		errs <- watchFolder(ctx, idxChan, idx, indexPath, src, 20*time.Millisecond, true, false, func() { close(ready) })
+	}()
+	select {
+	case <-ready:
+	case err := <-errs:
+		t.Fatalf("Watcher stopped: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watcher didn't become ready")
+	}
+
+	waitForIDs := func(expected ...string) {
+		t.Helper()
+		deadline := time.After(5 * time.Second)
+		for {
+			select {
+			case updated := <-idxChan:
+				require.Same(t, idx, updated)
+				if slices.Equal(documentIDs(t, i), expected) {
+					return
+				}
+			case err := <-errs:
+				t.Fatalf("Watcher stopped: %v", err)
+			case <-deadline:
+				t.Fatalf("Expected %v but got %v", expected, documentIDs(t, i))
+			}
+		}
+	}
+
+	writeDataFile(t, root, "conf-a/videos/two.json", `{"title": "Two"}`)
+	waitForIDs("session:conf-a:one", "session:conf-a:two")
+
+	writeDataFile(t, root, "conf-b/category.json", `{"title": "Conf B"}`)
+	writeDataFile(t, root, "conf-b/videos/three.json", `{"title": "Three"}`)
+	waitForIDs("session:conf-a:one", "session:conf-a:two", "session:conf-b:three")
+
+	writeDataFile(t, root, "conf-a/videos/two.json", `{"title": `)
+	require.NoError(t, os.Remove(filepath.Join(root, "conf-a/videos/one.json")))
+	waitForIDs("session:conf-a:two", "session:conf-b:three")
+
+	state, err := getIndexState(ctx, indexPath)
+	require.NoError(t, err)
+	require.Empty(t, state.Ref)
+
+	cancel()
+	require.NoError(t, <-errs)
+}
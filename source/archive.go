@@ -0,0 +1,318 @@
+package source
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var gzipMagic = []byte{0x1f, 0x8b}
+var zipMagic = []byte("PK\x03\x04")
+
+// Archive is a Source for a .tar.gz or .zip archive of the data. The
+// archive is either a local file or downloaded via HTTP and extracted into
+// a folder. If all collections of the archive are within a single folder,
+// its content is extracted instead.
+type Archive struct {
+	location string
+	// name is the location without credentials, which is safe to log.
+	name   string
+	client *http.Client
+	dir    *Directory
+	// version identifies the extracted archive. It is the ETag of a
+	// downloaded archive and the size and modification time of a local
+	// one.
+	version string
+}
+
+// NewArchive returns a source for the archive at location, which is either
+// a path or an HTTP(S) URL. The archive is extracted into p on Update.
+func NewArchive(location string, p string) *Archive {
+	return &Archive{
+		location: location,
+		name:     redactLocation(location),
+		client:   &http.Client{Timeout: 10 * time.Minute},
+		dir:      NewDirectory(p),
+	}
+}
+
+// Path returns the folder the archive is extracted into.
+func (a *Archive) Path() string {
+	return a.dir.Path()
+}
+
+// redactLocation strips the user info and the query, which might contain
+// credentials or signatures, from an archive URL. Paths are kept as they
+// are.
+func redactLocation(location string) string {
+	u, err := url.Parse(location)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return location
+	}
+	u.User = nil
+	u.RawQuery = ""
+	u.ForceQuery = false
+	u.Fragment = ""
+	return u.String()
+}
+
+func (a *Archive) remote() bool {
+	return strings.HasPrefix(a.location, "http://") || strings.HasPrefix(a.location, "https://")
+}
+
+// Update extracts the archive again if it changed since the last update.
+// Downloads are skipped if the server reports an unchanged ETag.
+func (a *Archive) Update(ctx context.Context) error {
+	if a.remote() {
+		return a.download(ctx)
+	}
+	info, err := os.Stat(a.location)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to read archive %s", a.location)
+	}
+	version := fmt.Sprintf("%d-%d", info.Size(), info.ModTime().UnixNano())
+	if version == a.version {
+		return nil
+	}
+	if err := a.install(a.location); err != nil {
+		return err
+	}
+	a.version = version
+	return nil
+}
+
+// Prepare extracts the archive as there is no data before that.
+func (a *Archive) Prepare(ctx context.Context) error {
+	return a.Update(ctx)
+}
+
+func (a *Archive) download(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.location, nil)
+	if err != nil {
+		return errors.Wrapf(unwrapURLError(err), "Invalid archive URL %s", a.name)
+	}
+	if a.version != "" {
+		req.Header.Set("If-None-Match", a.version)
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return errors.Wrapf(unwrapURLError(err), "Failed to download %s", a.name)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return errors.Wrapf(ErrAuthFailed, "Failed to download %s (%s)", a.name, resp.Status)
+	default:
+		return errors.Errorf("Failed to download %s: %s", a.name, resp.Status)
+	}
+
+	tmp, err := os.CreateTemp("", "pyvideosearch-archive-")
+	if err != nil {
+		return errors.Wrap(err, "Failed to create temporary file for the archive")
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	if _, err := io.Copy(tmp, resp.Body); err != nil {
+		return errors.Wrapf(err, "Failed to download %s", a.name)
+	}
+	if err := a.install(tmp.Name()); err != nil {
+		return err
+	}
+	a.version = resp.Header.Get("ETag")
+	return nil
+}
+
+// install extracts the archive file into a temporary folder next to the
+// data folder and then replaces the data folder with it.
+func (a *Archive) install(file string) error {
+	dataPath := a.Path()
+	parent := filepath.Dir(dataPath)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return errors.Wrapf(err, "Failed to create %s", parent)
+	}
+	tmp, err := os.MkdirTemp(parent, ".archive-")
+	if err != nil {
+		return errors.Wrapf(err, "Failed to create temporary folder in %s", parent)
+	}
+	defer os.RemoveAll(tmp)
+	if err := extract(file, tmp); err != nil {
+		return errors.Wrapf(err, "Failed to extract %s", a.name)
+	}
+
+	root := tmp
+	if entries, err := os.ReadDir(tmp); err == nil && len(entries) == 1 && entries[0].IsDir() {
+		// Archives like the ones of GitHub wrap everything in a folder,
+		// which is only a collection itself if it has a category.json.
+		if _, err := os.Stat(filepath.Join(tmp, entries[0].Name(), "category.json")); os.IsNotExist(err) {
+			root = filepath.Join(tmp, entries[0].Name())
+		}
+	}
+	if err := os.RemoveAll(dataPath); err != nil {
+		return errors.Wrapf(err, "Failed to remove old data in %s", dataPath)
+	}
+	if err := os.Rename(root, dataPath); err != nil {
+		return errors.Wrapf(err, "Failed to move extracted data to %s", dataPath)
+	}
+	return nil
+}
+
+// Ref returns a hash over the content of all extracted files.
+func (a *Archive) Ref(ctx context.Context) (Ref, error) {
+	ref, err := a.dir.Ref(ctx)
+	if err != nil {
+		return Ref{}, err
+	}
+	ref.Mode = "archive"
+	ref.Name = a.name
+	return ref, nil
+}
+
+// Changes compares the file hashes of two extracted archives.
+func (a *Archive) Changes(ctx context.Context, from string, to string) ([]string, error) {
+	return a.dir.Changes(ctx, from, to)
+}
+
+// unwrapURLError drops the URL that net/http adds to its errors as it
+// might contain credentials.
+func unwrapURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
+// extract detects the format of the archive file by its content and
+// extracts it into dest.
+func extract(file string, dest string) error {
+	fp, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(fp, magic); err != nil {
+		return errors.New("Unknown archive format")
+	}
+	if _, err := fp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return extractTarGz(fp, dest)
+	case bytes.HasPrefix(magic, zipMagic):
+		info, err := fp.Stat()
+		if err != nil {
+			return err
+		}
+		return extractZip(fp, info.Size(), dest)
+	}
+	return errors.New("Unknown archive format (expected .tar.gz or .zip)")
+}
+
+func extractTarGz(r io.Reader, dest string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		target, err := archivePath(dest, header.Name)
+		if err != nil {
+			return err
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeFile(target, tr); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func extractZip(r io.ReaderAt, size int64, dest string) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		target, err := archivePath(dest, f.Name)
+		if err != nil {
+			return err
+		}
+		switch {
+		case f.FileInfo().IsDir():
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case f.FileInfo().Mode().IsRegular():
+			rc, err := f.Open()
+			if err != nil {
+				return err
+			}
+			err = writeFile(target, rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// archivePath returns where the archive entry name is extracted to. Entries
+// pointing outside of dest are rejected.
+func archivePath(dest string, name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(name, "/") {
+		return "", errors.Errorf("Invalid path %s in archive", name)
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", errors.Errorf("Invalid path %s in archive", name)
+		}
+	}
+	return filepath.Join(dest, filepath.FromSlash(name)), nil
+}
+
+func writeFile(target string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	fp, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(fp, r); err != nil {
+		fp.Close()
+		return err
+	}
+	return fp.Close()
+}
@@ -0,0 +1,124 @@
+package source
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchiveLocalTarGz(t *testing.T) {
+	ctx := context.Background()
+	archivePath := filepath.Join(t.TempDir(), "data.tar.gz")
+	require.NoError(t, os.WriteFile(archivePath, tarGz(t, map[string]string{
+		"pyvideo-data-main/conf/category.json": `{}`,
+		"pyvideo-data-main/conf/videos/a.json": `{"title": "A"}`,
+	}), 0600))
+	dataPath := filepath.Join(t.TempDir(), "data")
+
+	src := NewArchive(archivePath, dataPath)
+	require.NoError(t, src.Update(ctx))
+	require.FileExists(t, filepath.Join(dataPath, "conf/videos/a.json"))
+	first, err := src.Ref(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "archive", first.Mode)
+	require.Equal(t, archivePath, first.Name)
+
+	require.NoError(t, os.WriteFile(archivePath, tarGz(t, map[string]string{
+		"pyvideo-data-main/conf/category.json": `{}`,
+		"pyvideo-data-main/conf/videos/b.json": `{"title": "B"}`,
+	}), 0600))
+	require.NoError(t, src.Update(ctx))
+	require.NoFileExists(t, filepath.Join(dataPath, "conf/videos/a.json"))
+	second, err := src.Ref(ctx)
+	require.NoError(t, err)
+	files, err := src.Changes(ctx, first.ID, second.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{"conf/videos/a.json", "conf/videos/b.json"}, files)
+}
+
+func TestArchiveHTTPZip(t *testing.T) {
+	ctx := context.Background()
+	data := zipArchive(t, map[string]string{
+		"conf/category.json": `{}`,
+		"conf/videos/a.json": `{"title": "A"}`,
+	})
+	var downloads int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		atomic.AddInt32(&downloads, 1)
+		w.Header().Set("ETag", `"v1"`)
+		w.Write(data)
+	}))
+	defer srv.Close()
+	dataPath := filepath.Join(t.TempDir(), "data")
+
+	location := strings.Replace(srv.URL, "http://", "http://user:pass@", 1) + "/data.zip?token=secret"
+	src := NewArchive(location, dataPath)
+	require.NoError(t, src.Prepare(ctx))
+	require.FileExists(t, filepath.Join(dataPath, "conf/videos/a.json"))
+	require.NoError(t, src.Update(ctx))
+	require.Equal(t, int32(1), atomic.LoadInt32(&downloads))
+	// Credentials in the URL must not end up in the state file or logs:
+	ref, err := src.Ref(ctx)
+	require.NoError(t, err)
+	require.Equal(t, srv.URL+"/data.zip", ref.Name)
+}
+
+func TestArchiveErrors(t *testing.T) {
+	ctx := context.Background()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+	err := NewArchive(srv.URL+"?token=secret", filepath.Join(t.TempDir(), "data")).Update(ctx)
+	require.ErrorIs(t, err, ErrAuthFailed)
+	require.NotContains(t, err.Error(), "secret")
+
+	archivePath := filepath.Join(t.TempDir(), "data.tar.gz")
+	require.NoError(t, os.WriteFile(archivePath, tarGz(t, map[string]string{"../evil.json": `{}`}), 0600))
+	require.Error(t, NewArchive(archivePath, filepath.Join(t.TempDir(), "data")).Update(ctx))
+
+	require.NoError(t, os.WriteFile(archivePath, []byte("not an archive"), 0600))
+	require.Error(t, NewArchive(archivePath, filepath.Join(t.TempDir(), "data")).Update(ctx))
+}
+
+func tarGz(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
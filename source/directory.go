@@ -0,0 +1,177 @@
+package source
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// maxSnapshots is the number of states a Directory remembers for
+// calculating changes. The index only ever compares the state it was
+// built from with the current one.
+const maxSnapshots = 2
+
+// Directory is a Source for a plain folder. Changes are detected by
+// hashing the content of all files.
+type Directory struct {
+	path string
+
+	lock      sync.Mutex
+	snapshots map[string]snapshot
+	order     []string
+}
+
+// NewDirectory returns a source for the folder p.
+func NewDirectory(p string) *Directory {
+	return &Directory{
+		path:      p,
+		snapshots: make(map[string]snapshot),
+	}
+}
+
+// Path returns the folder.
+func (d *Directory) Path() string {
+	return d.path
+}
+
+// Update does nothing as the folder is changed by someone else.
+func (d *Directory) Update(ctx context.Context) error {
+	return nil
+}
+
+// Ref returns a hash over the content of all files within the folder.
+func (d *Directory) Ref(ctx context.Context) (Ref, error) {
+	s, err := hashTree(ctx, d.path)
+	if err != nil {
+		return Ref{}, err
+	}
+	id := s.id()
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if _, ok := d.snapshots[id]; !ok {
+		d.snapshots[id] = s
+		d.order = append(d.order, id)
+		if len(d.order) > maxSnapshots {
+			delete(d.snapshots, d.order[0])
+			d.order = d.order[1:]
+		}
+	}
+	return Ref{ID: id, Mode: "directory"}, nil
+}
+
+// Changes compares the file hashes of two states. It only knows the states
+// most recently returned by Ref.
+func (d *Directory) Changes(ctx context.Context, from string, to string) ([]string, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	fromSnapshot, ok := d.snapshots[from]
+	if !ok {
+		return nil, errors.Errorf("Unknown state %s of %s", from, d.path)
+	}
+	toSnapshot, ok := d.snapshots[to]
+	if !ok {
+		return nil, errors.Errorf("Unknown state %s of %s", to, d.path)
+	}
+	return fromSnapshot.changes(toSnapshot), nil
+}
+
+// snapshot maps the slash-separated paths of all files in a folder to the
+// hashes of their content.
+type snapshot map[string]string
+
+// hashTree hashes all regular files within root. Hidden files and folders
+// like .git are skipped.
+func hashTree(ctx context.Context, root string) (snapshot, error) {
+	s := make(snapshot)
+	err := filepath.WalkDir(root, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if p != root && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		hash, err := hashFile(p)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		s[filepath.ToSlash(rel)] = hash
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to hash %s", root)
+	}
+	return s, nil
+}
+
+func hashFile(p string) (string, error) {
+	fp, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer fp.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, fp); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// id returns a hash over all paths and their hashes.
+func (s snapshot) id() string {
+	h := sha256.New()
+	for _, p := range s.paths() {
+		io.WriteString(h, p)
+		io.WriteString(h, "\x00")
+		io.WriteString(h, s[p])
+		io.WriteString(h, "\n")
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (s snapshot) paths() []string {
+	paths := make([]string, 0, len(s))
+	for p := range s {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// changes returns the paths of files that were added, modified or removed
+// in other.
+func (s snapshot) changes(other snapshot) []string {
+	files := make([]string, 0)
+	for _, p := range s.paths() {
+		if other[p] != s[p] {
+			files = append(files, p)
+		}
+	}
+	for _, p := range other.paths() {
+		if _, ok := s[p]; !ok {
+			files = append(files, p)
+		}
+	}
+	return files
+}
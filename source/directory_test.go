@@ -0,0 +1,55 @@
+package source
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirectory(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"conf/category.json": `{}`,
+		"conf/videos/a.json": `{"title": "A"}`,
+		"conf/videos/b.json": `{"title": "B"}`,
+		".git/HEAD":          "ref: refs/heads/main",
+	})
+
+	src := NewDirectory(root)
+	require.NoError(t, src.Update(ctx))
+	first, err := src.Ref(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "directory", first.Mode)
+	same, err := src.Ref(ctx)
+	require.NoError(t, err)
+	require.Equal(t, first, same)
+
+	writeFiles(t, root, map[string]string{
+		"conf/videos/a.json": `{"title": "A2"}`,
+		"conf/videos/c.json": `{"title": "C"}`,
+		".git/HEAD":          "ref: refs/heads/other",
+	})
+	require.NoError(t, os.Remove(filepath.Join(root, "conf/videos/b.json")))
+	second, err := src.Ref(ctx)
+	require.NoError(t, err)
+	require.NotEqual(t, first.ID, second.ID)
+
+	files, err := src.Changes(ctx, first.ID, second.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{"conf/videos/a.json", "conf/videos/b.json", "conf/videos/c.json"}, files)
+
+	_, err = src.Changes(ctx, "unknown", second.ID)
+	require.Error(t, err)
+}
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0600))
+	}
+}
@@ -0,0 +1,393 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/pkg/errors"
+)
+
+const defaultRemote = "origin"
+const defaultBranch = "main"
+
+// RefMode selects which upstream state a Git source checks out.
+type RefMode string
+
+const (
+	// RefModeBranch fast-forwards to the head of the followed branch.
+	RefModeBranch RefMode = "branch"
+	// RefModeTag checks out the newest tag matching a pattern.
+	RefModeTag RefMode = "tag"
+	// RefModeFixed checks out a pinned tag or commit.
+	RefModeFixed RefMode = "fixed"
+)
+
+// ParseRefMode parses the name of a RefMode.
+func ParseRefMode(v string) (RefMode, error) {
+	switch mode := RefMode(v); mode {
+	case RefModeBranch, RefModeTag, RefModeFixed:
+		return mode, nil
+	}
+	return "", errors.Errorf("Invalid ref mode %s (expected branch, tag or fixed)", v)
+}
+
+// GitOptions configures which upstream state a Git source follows.
+type GitOptions struct {
+	// Remote is the name of the remote to fetch from. Defaults to origin.
+	Remote string
+	// Branch is the upstream branch to follow. Defaults to main.
+	Branch string
+	// Mode selects how the upstream state is picked. Defaults to
+	// RefModeBranch.
+	Mode RefMode
+	// TagPattern is a glob (e.g. v*) the tags have to match in RefModeTag.
+	// All tags match if it is empty.
+	TagPattern string
+	// FixedRef is the tag or commit checked out in RefModeFixed.
+	FixedRef string
+}
+
+// Git is a Source backed by a checkout of the data repository. It doesn't
+// need a git binary.
+type Git struct {
+	path       string
+	remote     string
+	branch     string
+	mode       RefMode
+	tagPattern string
+	fixedRef   string
+}
+
+// NewGit returns a source for the checkout in p.
+func NewGit(p string, opts GitOptions) (*Git, error) {
+	g := &Git{
+		path:       p,
+		remote:     opts.Remote,
+		branch:     opts.Branch,
+		mode:       opts.Mode,
+		tagPattern: opts.TagPattern,
+		fixedRef:   opts.FixedRef,
+	}
+	if g.remote == "" {
+		g.remote = defaultRemote
+	}
+	if g.branch == "" {
+		g.branch = defaultBranch
+	}
+	if g.mode == "" {
+		g.mode = RefModeBranch
+	}
+	if g.tagPattern == "" {
+		g.tagPattern = "*"
+	}
+	if _, err := ParseRefMode(string(g.mode)); err != nil {
+		return nil, err
+	}
+	if _, err := path.Match(g.tagPattern, ""); err != nil {
+		return nil, errors.Wrapf(err, "Invalid tag pattern %s", g.tagPattern)
+	}
+	if g.mode == RefModeFixed && g.fixedRef == "" {
+		return nil, errors.New("A ref has to be set in the fixed ref mode")
+	}
+	return g, nil
+}
+
+// Path returns the folder of the checkout.
+func (g *Git) Path() string {
+	return g.path
+}
+
+func (g *Git) open() (*git.Repository, error) {
+	repo, err := git.PlainOpen(g.path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to open git repository at %s", g.path)
+	}
+	return repo, nil
+}
+
+// Fetch downloads what the ref mode needs: the new commits of the followed
+// branch as well as all tags in the branch mode, the tags in the tag mode
+// and the pinned tag in the fixed mode. A pinned commit is only fetched
+// along with the tags if it isn't available yet.
+func (g *Git) Fetch(ctx context.Context) error {
+	repo, err := g.open()
+	if err != nil {
+		return err
+	}
+	opts := &git.FetchOptions{RemoteName: g.remote, Tags: git.NoTags}
+	var what string
+	switch g.mode {
+	case RefModeBranch:
+		what = g.branch
+		opts.RefSpecs = []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:%s", plumbing.NewBranchReferenceName(g.branch), plumbing.NewRemoteReferenceName(g.remote, g.branch)))}
+		opts.Tags = git.AllTags
+	case RefModeTag:
+		what = "tags"
+		opts.RefSpecs = []config.RefSpec{"+refs/tags/*:refs/tags/*"}
+	case RefModeFixed:
+		if !plumbing.IsHash(g.fixedRef) {
+			what = g.fixedRef
+			tag := plumbing.NewTagReferenceName(g.fixedRef)
+			opts.RefSpecs = []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:%s", tag, tag))}
+			break
+		}
+		if _, err := repo.CommitObject(plumbing.NewHash(g.fixedRef)); err == nil {
+			return nil
+		}
+		// Not all servers allow fetching commits by their hash, so the
+		// commit has to be reachable from a tag.
+		what = "tags"
+		opts.RefSpecs = []config.RefSpec{"+refs/tags/*:refs/tags/*"}
+	}
+	err = repo.FetchContext(ctx, opts)
+	switch {
+	case err == nil || errors.Is(err, git.NoErrAlreadyUpToDate):
+		return nil
+	case errors.Is(err, transport.ErrAuthenticationRequired) || errors.Is(err, transport.ErrAuthorizationFailed) || errors.Is(err, transport.ErrInvalidAuthMethod):
+		return errors.Wrapf(ErrAuthFailed, "Failed to fetch %s from %s (%s)", what, g.remote, err)
+	}
+	return errors.Wrapf(err, "Failed to fetch %s from %s", what, g.remote)
+}
+
+// Resolve returns the commit hash of a revision like a branch, a tag or an
+// abbreviated hash.
+func (g *Git) Resolve(ctx context.Context, rev string) (string, error) {
+	repo, err := g.open()
+	if err != nil {
+		return "", err
+	}
+	commit, err := g.commit(repo, rev)
+	if err != nil {
+		return "", err
+	}
+	return commit.Hash.String(), nil
+}
+
+// NewestTag returns the name of the tag matching the tag pattern that
+// points to the most recent commit. Ties are broken by the tag name.
+func (g *Git) NewestTag(ctx context.Context) (string, error) {
+	repo, err := g.open()
+	if err != nil {
+		return "", err
+	}
+	tags, err := g.matchingTags(repo)
+	if err != nil {
+		return "", err
+	}
+	var newest string
+	var newestCommit *object.Commit
+	for name, commit := range tags {
+		if newestCommit == nil || commit.Committer.When.After(newestCommit.Committer.When) ||
+			(commit.Committer.When.Equal(newestCommit.Committer.When) && name > newest) {
+			newest, newestCommit = name, commit
+		}
+	}
+	if newest == "" {
+		return "", errors.Errorf("No tag matches %s", g.tagPattern)
+	}
+	return newest, nil
+}
+
+func (g *Git) matchingTags(repo *git.Repository) (map[string]*object.Commit, error) {
+	iter, err := repo.Tags()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to list tags")
+	}
+	tags := make(map[string]*object.Commit)
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name().Short()
+		if ok, _ := path.Match(g.tagPattern, name); !ok {
+			return nil
+		}
+		commit, err := g.commit(repo, ref.Name().String())
+		if err != nil {
+			return err
+		}
+		tags[name] = commit
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// FastForward moves the checked out branch to rev and updates the working
+// tree. Nothing happens if rev is already part of the branch. ErrDiverged
+// is returned if the branch contains commits rev doesn't.
+func (g *Git) FastForward(ctx context.Context, rev string) error {
+	repo, err := g.open()
+	if err != nil {
+		return err
+	}
+	target, err := g.commit(repo, rev)
+	if err != nil {
+		return err
+	}
+	head, err := g.commit(repo, "HEAD")
+	if err != nil {
+		return err
+	}
+	if head.Hash == target.Hash {
+		return nil
+	}
+	if ahead, err := target.IsAncestor(head); err != nil {
+		return errors.Wrapf(err, "Failed to compare HEAD with %s", rev)
+	} else if ahead {
+		return nil
+	}
+	if behind, err := head.IsAncestor(target); err != nil {
+		return errors.Wrapf(err, "Failed to compare HEAD with %s", rev)
+	} else if !behind {
+		return errors.Wrapf(ErrDiverged, "Failed to fast-forward %s to %s", head.Hash, rev)
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return errors.Wrapf(err, "Failed to open working tree of %s", g.path)
+	}
+	if err := worktree.Reset(&git.ResetOptions{Commit: target.Hash, Mode: git.MergeReset}); err != nil {
+		return errors.Wrapf(err, "Failed to fast-forward to %s", rev)
+	}
+	return nil
+}
+
+// Checkout detaches HEAD at rev and updates the working tree. Unlike
+// FastForward, rev may be older than or unrelated to the current HEAD.
+func (g *Git) Checkout(ctx context.Context, rev string) error {
+	repo, err := g.open()
+	if err != nil {
+		return err
+	}
+	target, err := g.commit(repo, rev)
+	if err != nil {
+		return err
+	}
+	if head, err := repo.Head(); err == nil && head.Hash() == target.Hash && !head.Name().IsBranch() {
+		return nil
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return errors.Wrapf(err, "Failed to open working tree of %s", g.path)
+	}
+	if err := worktree.Checkout(&git.CheckoutOptions{Hash: target.Hash}); err != nil {
+		return errors.Wrapf(err, "Failed to check out %s", rev)
+	}
+	return nil
+}
+
+// Update fetches from upstream and then checks out the state selected by
+// the ref mode.
+func (g *Git) Update(ctx context.Context) error {
+	if err := g.Fetch(ctx); err != nil {
+		return err
+	}
+	switch g.mode {
+	case RefModeTag:
+		tag, err := g.NewestTag(ctx)
+		if err != nil {
+			return err
+		}
+		return g.Checkout(ctx, plumbing.NewTagReferenceName(tag).String())
+	case RefModeFixed:
+		return g.Checkout(ctx, g.fixedRef)
+	}
+	return g.FastForward(ctx, plumbing.NewRemoteReferenceName(g.remote, g.branch).String())
+}
+
+// Prepare checks out the selected tag or the pinned ref, so that the
+// index isn't built from whatever was checked out before. Nothing happens
+// in the branch mode as the branch is only fast-forwarded by Update.
+func (g *Git) Prepare(ctx context.Context) error {
+	if g.mode == RefModeBranch {
+		return nil
+	}
+	return g.Update(ctx)
+}
+
+// Ref returns the hash of the checked out commit. Its name is the followed
+// branch, the matching tag pointing to it or the pinned ref if it is
+// checked out, depending on the ref mode.
+func (g *Git) Ref(ctx context.Context) (Ref, error) {
+	repo, err := g.open()
+	if err != nil {
+		return Ref{}, err
+	}
+	head, err := g.commit(repo, "HEAD")
+	if err != nil {
+		return Ref{}, err
+	}
+	ref := Ref{ID: head.Hash.String(), Mode: string(g.mode)}
+	switch g.mode {
+	case RefModeBranch:
+		ref.Name = plumbing.NewRemoteReferenceName(g.remote, g.branch).Short()
+	case RefModeTag:
+		tags, err := g.matchingTags(repo)
+		if err != nil {
+			return Ref{}, err
+		}
+		for name, commit := range tags {
+			if commit.Hash == head.Hash && name > ref.Name {
+				ref.Name = name
+			}
+		}
+	case RefModeFixed:
+		if fixed, err := g.commit(repo, g.fixedRef); err == nil && fixed.Hash == head.Hash {
+			ref.Name = g.fixedRef
+		}
+	}
+	return ref, nil
+}
+
+// Changes returns the files added, modified or removed between the commits
+// from and to. Renames are reported as a removal and an addition.
+func (g *Git) Changes(ctx context.Context, from string, to string) ([]string, error) {
+	repo, err := g.open()
+	if err != nil {
+		return nil, err
+	}
+	trees := make([]*object.Tree, 0, 2)
+	for _, rev := range []string{from, to} {
+		commit, err := g.commit(repo, rev)
+		if err != nil {
+			return nil, err
+		}
+		tree, err := commit.Tree()
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to read tree of %s", rev)
+		}
+		trees = append(trees, tree)
+	}
+	changes, err := trees[0].DiffContext(ctx, trees[1])
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to diff %s..%s", from, to)
+	}
+	files := make([]string, 0, len(changes))
+	for _, change := range changes {
+		if change.From.Name != "" {
+			files = append(files, change.From.Name)
+		}
+		if change.To.Name != "" && change.To.Name != change.From.Name {
+			files = append(files, change.To.Name)
+		}
+	}
+	return files, nil
+}
+
+func (g *Git) commit(repo *git.Repository, rev string) (*object.Commit, error) {
+	hash, err := repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to resolve %s", rev)
+	}
+	commit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to load commit %s", rev)
+	}
+	return commit, nil
+}
@@ -0,0 +1,244 @@
+package source
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitUpdate(t *testing.T) {
+	ctx := context.Background()
+	upstream := initRepo(t, "main")
+	first := commitFiles(t, upstream, map[string]string{"conf/category.json": `{}`, "conf/videos/a.json": `{}`})
+	local := cloneRepo(t, upstream, "origin")
+
+	src, err := NewGit(local, GitOptions{})
+	require.NoError(t, err)
+	ref, err := src.Ref(ctx)
+	require.NoError(t, err)
+	require.Equal(t, Ref{ID: first, Mode: "branch", Name: "origin/main"}, ref)
+
+	require.NoError(t, src.Update(ctx))
+	ref, err = src.Ref(ctx)
+	require.NoError(t, err)
+	require.Equal(t, first, ref.ID)
+
+	second := commitFiles(t, upstream, map[string]string{"conf/videos/a.json": "", "conf/videos/b.json": `{}`})
+	require.NoError(t, src.Update(ctx))
+	ref, err = src.Ref(ctx)
+	require.NoError(t, err)
+	require.Equal(t, second, ref.ID)
+	require.NoFileExists(t, filepath.Join(local, "conf/videos/a.json"))
+	require.FileExists(t, filepath.Join(local, "conf/videos/b.json"))
+
+	files, err := src.Changes(ctx, first, second)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"conf/videos/a.json", "conf/videos/b.json"}, files)
+}
+
+func TestGitUpdateBranch(t *testing.T) {
+	ctx := context.Background()
+	upstream := initRepo(t, "staging")
+	commitFiles(t, upstream, map[string]string{"conf/category.json": `{}`})
+	local := cloneRepo(t, upstream, "fork")
+
+	second := commitFiles(t, upstream, map[string]string{"conf/videos/a.json": `{}`})
+	src, err := NewGit(local, GitOptions{Remote: "fork", Branch: "staging"})
+	require.NoError(t, err)
+	require.NoError(t, src.Update(ctx))
+	ref, err := src.Ref(ctx)
+	require.NoError(t, err)
+	require.Equal(t, Ref{ID: second, Mode: "branch", Name: "fork/staging"}, ref)
+
+	src, err = NewGit(local, GitOptions{})
+	require.NoError(t, err)
+	require.Error(t, src.Update(ctx))
+}
+
+func TestGitUpdateDiverged(t *testing.T) {
+	ctx := context.Background()
+	upstream := initRepo(t, "main")
+	commitFiles(t, upstream, map[string]string{"conf/category.json": `{}`})
+	local := cloneRepo(t, upstream, "origin")
+
+	commitFiles(t, upstream, map[string]string{"conf/videos/a.json": `{}`})
+	ahead := commitFiles(t, local, map[string]string{"conf/videos/b.json": `{}`})
+
+	src, err := NewGit(local, GitOptions{})
+	require.NoError(t, err)
+	require.ErrorIs(t, src.Update(ctx), ErrDiverged)
+	ref, err := src.Ref(ctx)
+	require.NoError(t, err)
+	require.Equal(t, ahead, ref.ID)
+}
+
+func TestGitUpdateTag(t *testing.T) {
+	ctx := context.Background()
+	upstream := initRepo(t, "main")
+	first := commitFiles(t, upstream, map[string]string{"conf/category.json": `{}`})
+	tagCommit(t, upstream, "v1", first, false)
+	local := cloneRepo(t, upstream, "origin")
+
+	second := commitFiles(t, upstream, map[string]string{"conf/videos/a.json": `{}`})
+	tagCommit(t, upstream, "v2", second, true)
+	third := commitFiles(t, upstream, map[string]string{"conf/videos/b.json": `{}`})
+	tagCommit(t, upstream, "nightly", third, false)
+	commitFiles(t, upstream, map[string]string{"conf/videos/c.json": `{}`})
+
+	src, err := NewGit(local, GitOptions{Mode: RefModeTag, TagPattern: "v*"})
+	require.NoError(t, err)
+	require.NoError(t, src.Update(ctx))
+	ref, err := src.Ref(ctx)
+	require.NoError(t, err)
+	require.Equal(t, Ref{ID: second, Mode: "tag", Name: "v2"}, ref)
+	require.FileExists(t, filepath.Join(local, "conf/videos/a.json"))
+	require.NoFileExists(t, filepath.Join(local, "conf/videos/b.json"))
+
+	src, err = NewGit(local, GitOptions{Mode: RefModeTag, TagPattern: "release-*"})
+	require.NoError(t, err)
+	require.Error(t, src.Update(ctx))
+}
+
+func TestGitUpdateFixed(t *testing.T) {
+	ctx := context.Background()
+	upstream := initRepo(t, "main")
+	first := commitFiles(t, upstream, map[string]string{"conf/category.json": `{}`})
+	commitFiles(t, upstream, map[string]string{"conf/videos/a.json": `{}`})
+	local := cloneRepo(t, upstream, "origin")
+
+	src, err := NewGit(local, GitOptions{Mode: RefModeFixed, FixedRef: first})
+	require.NoError(t, err)
+	ref, err := src.Ref(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "", ref.Name)
+
+	require.NoError(t, src.Update(ctx))
+	ref, err = src.Ref(ctx)
+	require.NoError(t, err)
+	require.Equal(t, Ref{ID: first, Mode: "fixed", Name: first}, ref)
+	require.NoFileExists(t, filepath.Join(local, "conf/videos/a.json"))
+}
+
+// TestGitUpdateWithoutBranch checks that the tag and fixed modes work with
+// upstreams that don't have the followed branch.
+func TestGitUpdateWithoutBranch(t *testing.T) {
+	ctx := context.Background()
+	upstream := initRepo(t, "release")
+	first := commitFiles(t, upstream, map[string]string{"conf/category.json": `{}`})
+	local := cloneRepo(t, upstream, "origin")
+
+	second := commitFiles(t, upstream, map[string]string{"conf/videos/a.json": `{}`})
+	tagCommit(t, upstream, "v1", second, true)
+	third := commitFiles(t, upstream, map[string]string{"conf/videos/b.json": `{}`})
+	tagCommit(t, upstream, "nightly", third, false)
+
+	// Pinned commits that aren't available yet are fetched with the tags.
+	for _, rev := range []string{third, first} {
+		src, err := NewGit(local, GitOptions{Mode: RefModeFixed, FixedRef: rev})
+		require.NoError(t, err)
+		require.NoError(t, src.Update(ctx))
+		ref, err := src.Ref(ctx)
+		require.NoError(t, err)
+		require.Equal(t, Ref{ID: rev, Mode: "fixed", Name: rev}, ref)
+	}
+
+	src, err := NewGit(local, GitOptions{Mode: RefModeTag, TagPattern: "v*"})
+	require.NoError(t, err)
+	require.NoError(t, src.Update(ctx))
+	ref, err := src.Ref(ctx)
+	require.NoError(t, err)
+	require.Equal(t, Ref{ID: second, Mode: "tag", Name: "v1"}, ref)
+
+	src, err = NewGit(local, GitOptions{Mode: RefModeFixed, FixedRef: "v1"})
+	require.NoError(t, err)
+	require.NoError(t, src.Update(ctx))
+	ref, err = src.Ref(ctx)
+	require.NoError(t, err)
+	require.Equal(t, Ref{ID: second, Mode: "fixed", Name: "v1"}, ref)
+
+	src, err = NewGit(local, GitOptions{})
+	require.NoError(t, err)
+	require.Error(t, src.Update(ctx))
+}
+
+func TestNewGit(t *testing.T) {
+	_, err := NewGit(t.TempDir(), GitOptions{Mode: RefModeFixed})
+	require.Error(t, err)
+	_, err = NewGit(t.TempDir(), GitOptions{Mode: "latest"})
+	require.Error(t, err)
+	_, err = NewGit(t.TempDir(), GitOptions{Mode: RefModeTag, TagPattern: "v["})
+	require.Error(t, err)
+
+	_, err = ParseRefMode("tag")
+	require.NoError(t, err)
+	_, err = ParseRefMode("")
+	require.Error(t, err)
+}
+
+func initRepo(t *testing.T, branch string) string {
+	p := t.TempDir()
+	_, err := git.PlainInitWithOptions(p, &git.PlainInitOptions{
+		InitOptions: git.InitOptions{DefaultBranch: plumbing.NewBranchReferenceName(branch)},
+	})
+	require.NoError(t, err)
+	return p
+}
+
+func cloneRepo(t *testing.T, upstream string, remote string) string {
+	p := t.TempDir()
+	_, err := git.PlainClone(p, false, &git.CloneOptions{URL: upstream, RemoteName: remote})
+	require.NoError(t, err)
+	return p
+}
+
+// commitFiles writes the given files into the repository at p and commits
+// them. Files with empty content are removed instead.
+func commitFiles(t *testing.T, p string, files map[string]string) string {
+	repo, err := git.PlainOpen(p)
+	require.NoError(t, err)
+	worktree, err := repo.Worktree()
+	require.NoError(t, err)
+	for name, content := range files {
+		if content == "" {
+			_, err := worktree.Remove(name)
+			require.NoError(t, err)
+			continue
+		}
+		require.NoError(t, os.MkdirAll(filepath.Join(p, filepath.Dir(name)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(p, name), []byte(content), 0600))
+		_, err := worktree.Add(name)
+		require.NoError(t, err)
+	}
+	hash, err := worktree.Commit("Update data", &git.CommitOptions{
+		Author: nextSignature(),
+	})
+	require.NoError(t, err)
+	return hash.String()
+}
+
+func tagCommit(t *testing.T, p string, name string, hash string, annotated bool) {
+	repo, err := git.PlainOpen(p)
+	require.NoError(t, err)
+	var opts *git.CreateTagOptions
+	if annotated {
+		opts = &git.CreateTagOptions{Tagger: nextSignature(), Message: name}
+	}
+	_, err = repo.CreateTag(name, plumbing.NewHash(hash), opts)
+	require.NoError(t, err)
+}
+
+var signatureTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// nextSignature returns a signature a minute after the previous one so
+// that commits can be ordered by their date.
+func nextSignature() *object.Signature {
+	signatureTime = signatureTime.Add(time.Minute)
+	return &object.Signature{Name: "Test", Email: "test@example.org", When: signatureTime}
+}
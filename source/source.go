@@ -0,0 +1,53 @@
+// Package source provides access to the pyvideo data the search index is
+// built from and detects changes of it.
+package source
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrDiverged is returned if the local data can't be fast-forwarded to
+	// the upstream state as both contain changes the other one doesn't.
+	ErrDiverged = errors.New("Local and upstream history have diverged")
+	// ErrAuthFailed is returned if upstream rejected the credentials or
+	// requires some.
+	ErrAuthFailed = errors.New("Authentication with upstream failed")
+)
+
+// Ref identifies a state of the data.
+type Ref struct {
+	// ID changes whenever the data does, e.g. the hash of a commit.
+	ID string
+	// Mode describes how the state was selected, e.g. branch or tag.
+	Mode string
+	// Name is the branch, tag or pinned ref the state was selected by. It
+	// is empty if unknown.
+	Name string
+}
+
+// Source is a folder with the pyvideo data that can be updated from
+// upstream.
+type Source interface {
+	// Path returns the folder containing the data.
+	Path() string
+	// Update brings the data in Path up to date with upstream.
+	Update(ctx context.Context) error
+	// Ref identifies the current state of the data.
+	Ref(ctx context.Context) (Ref, error)
+	// Changes returns the files that differ between the states identified
+	// by the ref IDs from and to. The paths are relative to Path and use
+	// slashes.
+	Changes(ctx context.Context, from string, to string) ([]string, error)
+}
+
+// Preparer is implemented by sources whose data has to be updated before
+// the index is first built from it, e.g. because an archive has to be
+// extracted or a pinned ref checked out.
+type Preparer interface {
+	// Prepare brings the data in Path into the state the index is built
+	// from.
+	Prepare(ctx context.Context) error
+}
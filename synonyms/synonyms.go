@@ -0,0 +1,111 @@
+package synonyms
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Synonyms holds groups of equivalent terms like acronyms and their long
+// form. A file contains a list of groups, e.g.
+// [[ml, machine learning], [k8s, kubernetes]]. As YAML is a superset of
+// JSON, the same file can also be written as JSON. All terms of a group are
+// equivalent, so every term expands to all others.
+type Synonyms struct {
+	path string
+
+	lock     sync.RWMutex
+	groups   map[string][]string
+	maxWords int
+}
+
+// Load reads the synonyms from the file at path.
+func Load(path string) (*Synonyms, error) {
+	s := &Synonyms{path: path}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Parse creates synonyms from the content of a synonym file. These can't be
+// reloaded.
+func Parse(data []byte) (*Synonyms, error) {
+	s := &Synonyms{}
+	if err := s.update(data); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload reads the file again. The current synonyms are kept if the file
+// can't be read or parsed.
+func (s *Synonyms) Reload() error {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to read synonym file %s", s.path)
+	}
+	return errors.Wrapf(s.update(data), "Failed to parse synonym file %s", s.path)
+}
+
+func (s *Synonyms) update(data []byte) error {
+	var groups [][]string
+	if err := yaml.Unmarshal(data, &groups); err != nil {
+		return err
+	}
+	lookup := make(map[string][]string)
+	maxWords := 0
+	for _, group := range groups {
+		terms := make([]string, 0, len(group))
+		for _, term := range group {
+			if term = normalize(term); term != "" {
+				terms = append(terms, term)
+			}
+		}
+		for _, term := range terms {
+			lookup[term] = append(lookup[term], terms...)
+			if words := len(strings.Fields(term)); words > maxWords {
+				maxWords = words
+			}
+		}
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.groups = lookup
+	s.maxWords = maxWords
+	return nil
+}
+
+// Expand returns the synonyms of all terms found in input. Terms with more
+// than one word are only found if the words appear consecutively. A nil
+// Synonyms never expands anything.
+func (s *Synonyms) Expand(input string) []string {
+	if s == nil {
+		return nil
+	}
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	words := strings.Fields(normalize(input))
+	var expansions []string
+	seen := make(map[string]struct{})
+	for start := range words {
+		for n := 1; n <= s.maxWords && start+n <= len(words); n++ {
+			phrase := strings.Join(words[start:start+n], " ")
+			for _, synonym := range s.groups[phrase] {
+				if _, ok := seen[synonym]; ok || synonym == phrase {
+					continue
+				}
+				seen[synonym] = struct{}{}
+				expansions = append(expansions, synonym)
+			}
+		}
+	}
+	return expansions
+}
+
+func normalize(term string) string {
+	return strings.Join(strings.Fields(strings.ToLower(term)), " ")
+}
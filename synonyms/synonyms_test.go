@@ -0,0 +1,66 @@
+package synonyms_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zerok/pyvideosearch/synonyms"
+)
+
+var synonymFile = `
+- [ML, machine learning]
+- [k8s, kubernetes]
+- [DRF, Django REST Framework]
+- [async, asyncio]
+`
+
+func TestExpand(t *testing.T) {
+	s, err := synonyms.Parse([]byte(synonymFile))
+	require.NoError(t, err)
+	testcases := []struct {
+		input    string
+		expected []string
+	}{
+		{input: "ML", expected: []string{"machine learning"}},
+		{input: "Machine  Learning in production", expected: []string{"ml"}},
+		{input: "deploying on k8s with drf", expected: []string{"kubernetes", "django rest framework"}},
+		{input: "django rest framework", expected: []string{"drf"}},
+		{input: "asyncio", expected: []string{"async"}},
+		{input: "machine", expected: nil},
+	}
+	for _, testcase := range testcases {
+		t.Run(testcase.input, func(t *testing.T) {
+			require.Equal(t, testcase.expected, s.Expand(testcase.input))
+		})
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	s, err := synonyms.Parse([]byte(`[["k8s", "kubernetes"]]`))
+	require.NoError(t, err)
+	require.Equal(t, []string{"k8s"}, s.Expand("Kubernetes"))
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "synonyms.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`- [ml, machine learning]`), 0600))
+	s, err := synonyms.Load(path)
+	require.NoError(t, err)
+	require.Equal(t, []string{"machine learning"}, s.Expand("ml"))
+
+	require.NoError(t, os.WriteFile(path, []byte(`- [ml, maximum likelihood]`), 0600))
+	require.NoError(t, s.Reload())
+	require.Equal(t, []string{"maximum likelihood"}, s.Expand("ml"))
+
+	// Broken files don't replace the current synonyms.
+	require.NoError(t, os.WriteFile(path, []byte(`- [ml`), 0600))
+	require.Error(t, s.Reload())
+	require.Equal(t, []string{"maximum likelihood"}, s.Expand("ml"))
+}
+
+func TestExpandNil(t *testing.T) {
+	var s *synonyms.Synonyms
+	require.Nil(t, s.Expand("ml"))
+}